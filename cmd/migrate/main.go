@@ -0,0 +1,72 @@
+// Command migrate manages the database schema.
+//
+//	go run ./cmd/migrate up           apply all pending migrations
+//	go run ./cmd/migrate down [N]     roll back the last N migrations (default 1)
+//	go run ./cmd/migrate status       list migrations and when they were applied
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"vcm-medical-platform/config"
+	"vcm-medical-platform/database"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate up | down [N] | status")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := database.Connect(cfg.Database); err != nil {
+		log.Fatal(err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "up", "migrate":
+		err = database.MigrateUp(ctx)
+
+	case "down", "rollback":
+		steps := 1
+		if len(os.Args) > 2 {
+			if steps, err = strconv.Atoi(os.Args[2]); err != nil {
+				log.Fatalf("invalid step count %q", os.Args[2])
+			}
+		}
+		err = database.Rollback(ctx, steps)
+
+	case "status":
+		var statuses []database.MigrationStatus
+		statuses, err = database.Status(ctx)
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%04d  %-40s  %s\n", s.Version, s.Name, applied)
+		}
+
+	default:
+		usage()
+	}
+
+	if err != nil {
+		database.Close()
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,693 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// DefaultAppSecret is only acceptable outside production
+const DefaultAppSecret = "default-app-secret-change-in-production"
+
+// Config holds every setting the application reads at startup
+type Config struct {
+	Environment string
+	Port        string
+	FrontendURL string
+
+	// AppSecret keys HMACs over stored one-time codes and signed links, and
+	// encrypts secrets at rest such as JWT signing keys
+	AppSecret string
+
+	// TrustedProxies may set X-Forwarded-For; client IPs come from that header
+	// only when the request arrives from one of these addresses or CIDRs
+	TrustedProxies []string
+
+	// InviteExpire is how long a staff invitation link stays valid
+	InviteExpire time.Duration
+
+	Database  DatabaseConfig
+	JWT       JWTConfig
+	SMTP      SMTPConfig
+	Notify    NotifyConfig
+	WeChat    WeChatOAuthConfig
+	OIDC      OIDCConfig
+	MFA       MFAConfig
+	RateLimit RateLimitConfig
+	Password  PasswordConfig
+	Privacy   PrivacyConfig
+	Audit     LoginAuditConfig
+	// Passwordless controls login by emailed link or code
+	Passwordless PasswordlessConfig
+	WebAuthn     WebAuthnConfig
+}
+
+type DatabaseConfig struct {
+	URL      string
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	LogQueries      bool
+}
+
+type JWTConfig struct {
+	// Expire is the access token lifetime; keep it short
+	Expire time.Duration
+	// RefreshExpire is how long an unused refresh token stays valid
+	RefreshExpire time.Duration
+	Issuer        string
+	Audience      string
+	// KeyRotation is how long each signing key is used before the next takes over
+	KeyRotation time.Duration
+}
+
+// keyRefreshMaxInterval caps how long an instance goes without reloading the key ring
+const keyRefreshMaxInterval = time.Hour
+
+// KeyRefreshInterval is how often the key ring is reloaded and rotated. The
+// next key is created when a quarter of the rotation period is left, so the
+// ring is reloaded at least twice in that window and every instance holds the
+// next key before the current one stops signing.
+func (j JWTConfig) KeyRefreshInterval() time.Duration {
+	if interval := j.KeyRotation / 8; interval < keyRefreshMaxInterval {
+		return interval
+	}
+	return keyRefreshMaxInterval
+}
+
+type SMTPConfig struct {
+	Host string
+	Port int
+	User string
+	Pass string
+	From string
+}
+
+type NotifyConfig struct {
+	// Fake records messages in memory instead of sending them; their codes
+	// and links are logged only in development
+	Fake   bool
+	SMS    SMSConfig
+	WeChat WeChatMPConfig
+}
+
+// SMSConfig holds Aliyun SMS credentials and template codes
+type SMSConfig struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	SignName        string
+	Region          string
+	OTPTemplate     string
+}
+
+// IsConfigured reports whether SMS can be sent
+func (s SMSConfig) IsConfigured() bool {
+	return s.AccessKeyID != "" && s.AccessKeySecret != "" && s.SignName != "" && s.OTPTemplate != ""
+}
+
+// WeChatMPConfig holds the WeChat Official Account used for template messages
+type WeChatMPConfig struct {
+	AppID         string
+	AppSecret     string
+	OTPTemplateID string
+}
+
+// IsConfigured reports whether WeChat template messages can be sent
+func (w WeChatMPConfig) IsConfigured() bool {
+	return w.AppID != "" && w.AppSecret != "" && w.OTPTemplateID != ""
+}
+
+// WeChatOAuthConfig configures "Log in with WeChat". The endpoints are
+// configurable so a local stub can stand in for WeChat during tests.
+type WeChatOAuthConfig struct {
+	AppID        string
+	AppSecret    string
+	RedirectURL  string
+	Scope        string
+	AuthorizeURL string
+	TokenURL     string
+	UserInfoURL  string
+}
+
+// IsConfigured reports whether WeChat login is enabled
+func (w WeChatOAuthConfig) IsConfigured() bool {
+	return w.AppID != "" && w.AppSecret != "" && w.RedirectURL != ""
+}
+
+// OIDCConfig configures the OpenID Connect provider partner portals log in through
+type OIDCConfig struct {
+	// Issuer is the public base URL of this API; empty disables the provider
+	Issuer string
+	// ConsentURL is the frontend page that signs the user in and asks for consent
+	ConsentURL string
+	// TokenTTL is the lifetime of access tokens and ID tokens issued to partners
+	TokenTTL time.Duration
+}
+
+// IsConfigured reports whether the OpenID Connect provider is enabled
+func (o OIDCConfig) IsConfigured() bool {
+	return o.Issuer != ""
+}
+
+// WebAuthnConfig identifies this site to passkeys and security keys
+type WebAuthnConfig struct {
+	// RPID is the domain passkeys are bound to; empty disables passkeys
+	RPID string
+	// RPName is shown by the browser when creating a passkey
+	RPName string
+	// Origins are the frontend origins allowed to run the ceremonies
+	Origins []string
+}
+
+// IsConfigured reports whether passkeys are enabled
+func (w WebAuthnConfig) IsConfigured() bool {
+	return w.RPID != ""
+}
+
+// PasswordConfig is the password policy and the argon2id cost of new hashes.
+// Raising the cost rehashes existing passwords as users log in.
+type PasswordConfig struct {
+	MinLength int
+	MaxLength int
+	// BreachedListPath is a file of SHA-1 password hashes, one per line in
+	// hex, optionally followed by ":count" as in the Pwned Passwords dumps
+	BreachedListPath string
+
+	Argon2Memory      uint32 // KiB
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// LoginAuditConfig controls the login history and new sign-in alerts
+type LoginAuditConfig struct {
+	// CountryHeader is a header set by the CDN or proxy with the client's
+	// ISO country code, e.g. CF-IPCountry; empty means countries are unknown
+	CountryHeader string
+	// Alerts emails users when they log in from a new device or country
+	Alerts bool
+}
+
+// PasswordlessConfig controls login by emailed magic link or code
+type PasswordlessConfig struct {
+	// UserTypes may log in without a password; empty disables passwordless login
+	UserTypes []int
+	// TTL is how long a link or code can be used; codes never outlive 10 minutes
+	TTL time.Duration
+}
+
+// IsAllowedFor reports whether userType may log in without a password
+func (p PasswordlessConfig) IsAllowedFor(userType int) bool {
+	for _, t := range p.UserTypes {
+		if t == userType {
+			return true
+		}
+	}
+	return false
+}
+
+// PrivacyConfig governs personal data exports and erasure requests
+type PrivacyConfig struct {
+	// ExportTTL is how long a generated export archive can be downloaded
+	ExportTTL time.Duration
+	// ErasureGracePeriod delays erasure so a mistaken request can be cancelled
+	ErasureGracePeriod time.Duration
+}
+
+// RateLimit allows Limit requests per sliding Window
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// RateLimitConfig holds the request budgets of the authentication endpoints
+type RateLimitConfig struct {
+	Enabled bool
+	// Store is "memory" for a single instance or "postgres" to share counters
+	// between instances
+	Store string
+	// Budgets are keyed "<route>.<ip|email>"; a zero Limit disables the budget
+	Budgets map[string]RateLimit
+}
+
+// DefaultRateLimits are the budgets used unless overridden by
+// RATE_LIMIT_<ROUTE>_<KEY>, e.g. RATE_LIMIT_LOGIN_EMAIL=10/15m
+var DefaultRateLimits = map[string]RateLimit{
+	"login.ip":              {Limit: 30, Window: time.Minute},
+	"login.email":           {Limit: 10, Window: 15 * time.Minute},
+	"login_2fa.ip":          {Limit: 30, Window: 10 * time.Minute},
+	"register.ip":           {Limit: 10, Window: time.Hour},
+	"verify_otp.ip":         {Limit: 30, Window: 10 * time.Minute},
+	"verify_otp.email":      {Limit: 10, Window: 10 * time.Minute},
+	"resend_otp.ip":         {Limit: 10, Window: time.Hour},
+	"resend_otp.email":      {Limit: 3, Window: 10 * time.Minute},
+	"password_forgot.ip":    {Limit: 10, Window: time.Hour},
+	"password_forgot.email": {Limit: 3, Window: 10 * time.Minute},
+	"password_reset.ip":     {Limit: 30, Window: 10 * time.Minute},
+	"password_reset.email":  {Limit: 10, Window: 10 * time.Minute},
+	"contact_undo.ip":       {Limit: 10, Window: time.Hour},
+	"passwordless.ip":       {Limit: 10, Window: time.Hour},
+	"passwordless.email":    {Limit: 3, Window: 10 * time.Minute},
+	"passwordless_use.ip":   {Limit: 30, Window: 10 * time.Minute},
+	"passkey_login.ip":      {Limit: 30, Window: 10 * time.Minute},
+	"wechat_callback.ip":    {Limit: 30, Window: 10 * time.Minute},
+	"wechat_register.ip":    {Limit: 10, Window: time.Hour},
+}
+
+type MFAConfig struct {
+	// Issuer is the account label shown in authenticator apps
+	Issuer string
+	// RequiredUserTypes must enrol in two-factor authentication before logging in
+	RequiredUserTypes []int
+}
+
+// IsRequiredFor reports whether two-factor authentication is mandatory for userType
+func (m MFAConfig) IsRequiredFor(userType int) bool {
+	for _, t := range m.RequiredUserTypes {
+		if t == userType {
+			return true
+		}
+	}
+	return false
+}
+
+// DSN returns DATABASE_URL when set, otherwise a key/value DSN built from the DB_* settings
+func (d DatabaseConfig) DSN() string {
+	if d.URL != "" {
+		return d.URL
+	}
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		d.Host, d.Port, d.User, d.Password, d.Name, d.SSLMode)
+}
+
+// IsConfigured reports whether the SMTP credentials are present
+func (s SMTPConfig) IsConfigured() bool {
+	return s.Host != "" && s.User != "" && s.Pass != ""
+}
+
+func (c *Config) IsProduction() bool {
+	return c.Environment == "production"
+}
+
+func (c *Config) IsDevelopment() bool {
+	return c.Environment == "development"
+}
+
+// SignedTokenTTL is the longest lifetime of a token signed with the JWT key
+// ring: access tokens, and ID tokens when the OpenID provider is enabled
+func (c *Config) SignedTokenTTL() time.Duration {
+	ttl := c.JWT.Expire
+	if c.OIDC.IsConfigured() && c.OIDC.TokenTTL > ttl {
+		ttl = c.OIDC.TokenTTL
+	}
+	return ttl
+}
+
+// Load reads an optional env file (ENV_FILE, default .env) and the process
+// environment into a Config, then validates it. Variables already set in the
+// environment win over the file. Any KEY may also be supplied as KEY_FILE
+// pointing at a file holding the value, for mounted secrets.
+func Load() (*Config, error) {
+	envFile := os.Getenv("ENV_FILE")
+	if envFile == "" {
+		envFile = ".env"
+	}
+	if err := godotenv.Load(envFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load %s: %w", envFile, err)
+	}
+
+	var errs []error
+	l := loader{errs: &errs}
+
+	environment := l.get("ENVIRONMENT", l.get("APP_ENV", "development"))
+
+	cfg := &Config{
+		Environment: strings.ToLower(environment),
+		Port:        l.get("PORT", "8080"),
+		FrontendURL: l.get("FRONTEND_URL", ""),
+		AppSecret:   l.get("APP_SECRET", ""),
+
+		TrustedProxies: l.getList("TRUSTED_PROXIES"),
+
+		InviteExpire: l.getDuration("INVITE_EXPIRE", 7*24*time.Hour),
+		Database: DatabaseConfig{
+			URL:             l.get("DATABASE_URL", ""),
+			Host:            l.get("DB_HOST", "localhost"),
+			Port:            l.get("DB_PORT", "5432"),
+			User:            l.get("DB_USER", ""),
+			Password:        l.get("DB_PASSWORD", ""),
+			Name:            l.get("DB_NAME", ""),
+			SSLMode:         l.get("DB_SSLMODE", "disable"),
+			MaxOpenConns:    l.getInt("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:    l.getInt("DB_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetime: l.getDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		},
+		JWT: JWTConfig{
+			Issuer:      l.get("JWT_ISSUER", "vcm-medical-platform"),
+			Audience:    l.get("JWT_AUDIENCE", "vcm-medical-platform-api"),
+			KeyRotation: l.getDuration("JWT_KEY_ROTATION", 30*24*time.Hour),
+		},
+		SMTP: SMTPConfig{
+			Host: l.get("SMTP_HOST", ""),
+			Port: l.getInt("SMTP_PORT", 587),
+			User: l.get("SMTP_USER", ""),
+			Pass: l.get("SMTP_PASS", ""),
+		},
+		Notify: NotifyConfig{
+			SMS: SMSConfig{
+				AccessKeyID:     l.get("SMS_ACCESS_KEY_ID", ""),
+				AccessKeySecret: l.get("SMS_ACCESS_KEY_SECRET", ""),
+				SignName:        l.get("SMS_SIGN_NAME", ""),
+				Region:          l.get("SMS_REGION", "cn-hangzhou"),
+				OTPTemplate:     l.get("SMS_OTP_TEMPLATE", ""),
+			},
+			WeChat: WeChatMPConfig{
+				AppID:         l.get("WECHAT_MP_APP_ID", ""),
+				AppSecret:     l.get("WECHAT_MP_APP_SECRET", ""),
+				OTPTemplateID: l.get("WECHAT_MP_OTP_TEMPLATE_ID", ""),
+			},
+		},
+		WeChat: WeChatOAuthConfig{
+			AppID:        l.get("WECHAT_OAUTH_APP_ID", ""),
+			AppSecret:    l.get("WECHAT_OAUTH_APP_SECRET", ""),
+			RedirectURL:  l.get("WECHAT_OAUTH_REDIRECT_URL", ""),
+			Scope:        l.get("WECHAT_OAUTH_SCOPE", "snsapi_login"),
+			AuthorizeURL: l.get("WECHAT_OAUTH_AUTHORIZE_URL", "https://open.weixin.qq.com/connect/qrconnect"),
+			TokenURL:     l.get("WECHAT_OAUTH_TOKEN_URL", "https://api.weixin.qq.com/sns/oauth2/access_token"),
+			UserInfoURL:  l.get("WECHAT_OAUTH_USERINFO_URL", "https://api.weixin.qq.com/sns/userinfo"),
+		},
+		OIDC: OIDCConfig{
+			Issuer:   strings.TrimRight(l.get("OIDC_ISSUER", ""), "/"),
+			TokenTTL: l.getDuration("OIDC_TOKEN_TTL", time.Hour),
+		},
+		MFA: MFAConfig{
+			Issuer: l.get("MFA_ISSUER", "VCM Medical Platform"),
+			// Doctors, Operators, Admins and Super Admins by default
+			RequiredUserTypes: l.getIntList("MFA_REQUIRED_USER_TYPES", []int{5, 10, 11, 12}),
+		},
+	}
+
+	cfg.Password = PasswordConfig{
+		MinLength:        l.getInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:        l.getInt("PASSWORD_MAX_LENGTH", 128),
+		BreachedListPath: l.get("PASSWORD_BREACHED_LIST", ""),
+		// OWASP's recommended minimum for argon2id
+		Argon2Memory:      uint32(l.getInt("PASSWORD_ARGON2_MEMORY_KIB", 19*1024)),
+		Argon2Iterations:  uint32(l.getInt("PASSWORD_ARGON2_ITERATIONS", 2)),
+		Argon2Parallelism: uint8(l.getInt("PASSWORD_ARGON2_PARALLELISM", 1)),
+	}
+
+	cfg.Audit = LoginAuditConfig{
+		CountryHeader: l.get("LOGIN_COUNTRY_HEADER", ""),
+		Alerts:        l.getBool("LOGIN_ALERTS_ENABLED", true),
+	}
+
+	cfg.Passwordless = PasswordlessConfig{
+		// Patients by default
+		UserTypes: l.getIntList("PASSWORDLESS_USER_TYPES", []int{0}),
+		TTL:       l.getDuration("PASSWORDLESS_TTL", 10*time.Minute),
+	}
+
+	cfg.Privacy = PrivacyConfig{
+		ExportTTL:          l.getDuration("PRIVACY_EXPORT_TTL", 7*24*time.Hour),
+		ErasureGracePeriod: l.getDuration("PRIVACY_ERASURE_GRACE_PERIOD", 14*24*time.Hour),
+	}
+
+	cfg.RateLimit = RateLimitConfig{
+		Enabled: l.getBool("RATE_LIMIT_ENABLED", true),
+		Store:   strings.ToLower(l.get("RATE_LIMIT_STORE", "memory")),
+		Budgets: map[string]RateLimit{},
+	}
+	for name, def := range DefaultRateLimits {
+		envKey := "RATE_LIMIT_" + strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+		cfg.RateLimit.Budgets[name] = l.getRateLimit(envKey, def)
+	}
+
+	// JWT_EXPIRE (e.g. 15m) supersedes the legacy JWT_EXPIRES_HOURS
+	cfg.JWT.Expire = 15 * time.Minute
+	if hours := l.getInt("JWT_EXPIRES_HOURS", 0); hours > 0 {
+		cfg.JWT.Expire = time.Duration(hours) * time.Hour
+	}
+	cfg.JWT.Expire = l.getDuration("JWT_EXPIRE", cfg.JWT.Expire)
+	cfg.JWT.RefreshExpire = l.getDuration("JWT_REFRESH_EXPIRE", 30*24*time.Hour)
+
+	cfg.Database.LogQueries = l.getBool("DB_LOG_QUERIES", cfg.IsDevelopment())
+	cfg.SMTP.From = l.get("SMTP_FROM", cfg.SMTP.User)
+	cfg.OIDC.ConsentURL = l.get("OIDC_CONSENT_URL", strings.TrimRight(cfg.FrontendURL, "/")+"/oauth/authorize")
+	cfg.Notify.Fake = l.getBool("NOTIFY_FAKE", cfg.IsDevelopment() || cfg.Environment == "test")
+
+	// Passkeys default to the frontend's host and origin
+	cfg.WebAuthn = WebAuthnConfig{
+		RPID:    l.get("WEBAUTHN_RP_ID", frontendHost(cfg.FrontendURL)),
+		RPName:  l.get("WEBAUTHN_RP_NAME", "VCM Medical Platform"),
+		Origins: l.getList("WEBAUTHN_ORIGINS"),
+	}
+	if len(cfg.WebAuthn.Origins) == 0 && cfg.FrontendURL != "" {
+		cfg.WebAuthn.Origins = []string{strings.TrimRight(cfg.FrontendURL, "/")}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if cfg.AppSecret == "" && !cfg.IsProduction() {
+		log.Println("⚠️  APP_SECRET not set, using insecure development default")
+		cfg.AppSecret = DefaultAppSecret
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate rejects configurations that are unsafe or incomplete
+func (c *Config) Validate() error {
+	var errs []error
+
+	switch c.Environment {
+	case "development", "staging", "production", "test":
+	default:
+		errs = append(errs, fmt.Errorf("ENVIRONMENT must be one of development, staging, production, test (got %q)", c.Environment))
+	}
+
+	if c.Database.URL == "" && (c.Database.User == "" || c.Database.Name == "") {
+		errs = append(errs, errors.New("database not configured: set DATABASE_URL or DB_USER and DB_NAME"))
+	}
+	if c.JWT.Expire <= 0 {
+		errs = append(errs, errors.New("JWT_EXPIRE must be positive"))
+	}
+	if c.InviteExpire <= 0 {
+		errs = append(errs, errors.New("INVITE_EXPIRE must be positive"))
+	}
+	if c.JWT.RefreshExpire <= c.JWT.Expire {
+		errs = append(errs, errors.New("JWT_REFRESH_EXPIRE must be longer than JWT_EXPIRE"))
+	}
+	if c.JWT.KeyRotation <= c.JWT.Expire {
+		errs = append(errs, errors.New("JWT_KEY_ROTATION must be longer than JWT_EXPIRE"))
+	}
+
+	if c.OIDC.IsConfigured() {
+		if c.OIDC.TokenTTL <= 0 {
+			errs = append(errs, errors.New("OIDC_TOKEN_TTL must be positive"))
+		}
+		if c.IsProduction() && !strings.HasPrefix(c.OIDC.Issuer, "https://") {
+			errs = append(errs, errors.New("OIDC_ISSUER must be an https URL in production"))
+		}
+	}
+
+	if c.WebAuthn.IsConfigured() && len(c.WebAuthn.Origins) == 0 {
+		errs = append(errs, errors.New("WEBAUTHN_ORIGINS must be set when WEBAUTHN_RP_ID is"))
+	}
+
+	if c.Password.MinLength < 6 || c.Password.MaxLength < c.Password.MinLength {
+		errs = append(errs, errors.New("PASSWORD_MIN_LENGTH must be at least 6 and not above PASSWORD_MAX_LENGTH"))
+	}
+	if c.Password.Argon2Memory < 8*1024 || c.Password.Argon2Iterations < 1 || c.Password.Argon2Parallelism < 1 {
+		errs = append(errs, errors.New("argon2 parameters too weak: need at least 8192 KiB memory, 1 iteration and 1 thread"))
+	}
+
+	if c.Passwordless.TTL <= 0 {
+		errs = append(errs, errors.New("PASSWORDLESS_TTL must be positive"))
+	}
+
+	if c.Privacy.ExportTTL <= 0 {
+		errs = append(errs, errors.New("PRIVACY_EXPORT_TTL must be positive"))
+	}
+	if c.Privacy.ErasureGracePeriod < 0 {
+		errs = append(errs, errors.New("PRIVACY_ERASURE_GRACE_PERIOD must not be negative"))
+	}
+
+	if c.RateLimit.Store != "memory" && c.RateLimit.Store != "postgres" {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_STORE must be memory or postgres (got %q)", c.RateLimit.Store))
+	}
+
+	if c.IsProduction() {
+		// Otherwise codes and login links would never reach anyone
+		if !c.SMTP.IsConfigured() {
+			errs = append(errs, errors.New("SMTP must be configured in production (set SMTP_HOST, SMTP_USER and SMTP_PASS)"))
+		}
+		if c.Notify.Fake {
+			errs = append(errs, errors.New("NOTIFY_FAKE must not be enabled in production"))
+		}
+		if c.AppSecret == "" || c.AppSecret == DefaultAppSecret {
+			errs = append(errs, errors.New("APP_SECRET must be set to a non-default value in production"))
+		} else if len(c.AppSecret) < 32 {
+			errs = append(errs, errors.New("APP_SECRET must be at least 32 characters in production"))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// loader reads typed values from the environment, collecting parse errors
+type loader struct {
+	errs *[]error
+}
+
+func (l loader) lookup(key string) (string, bool) {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v, true
+	}
+	if path, ok := os.LookupEnv(key + "_FILE"); ok && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			*l.errs = append(*l.errs, fmt.Errorf("%s_FILE: %w", key, err))
+			return "", false
+		}
+		return strings.TrimSpace(string(data)), true
+	}
+	return "", false
+}
+
+func (l loader) get(key, def string) string {
+	if v, ok := l.lookup(key); ok {
+		return v
+	}
+	return def
+}
+
+func (l loader) getInt(key string, def int) int {
+	v, ok := l.lookup(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		*l.errs = append(*l.errs, fmt.Errorf("%s: invalid integer %q", key, v))
+		return def
+	}
+	return n
+}
+
+// getList parses a comma-separated list of strings
+func (l loader) getList(key string) []string {
+	v, _ := l.lookup(key)
+
+	var list []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+// frontendHost is the host name of the frontend URL, or "" if it has none
+func frontendHost(frontendURL string) string {
+	u, err := url.Parse(frontendURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// getIntList parses a comma-separated list such as "5,10,11"; an empty value means none
+func (l loader) getIntList(key string, def []int) []int {
+	v, ok := l.lookup(key)
+	if !ok {
+		// Explicitly set but empty disables the list
+		if _, set := os.LookupEnv(key); set {
+			return nil
+		}
+		return def
+	}
+
+	var list []int
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			*l.errs = append(*l.errs, fmt.Errorf("%s: invalid integer %q", key, part))
+			return def
+		}
+		list = append(list, n)
+	}
+	return list
+}
+
+func (l loader) getBool(key string, def bool) bool {
+	v, ok := l.lookup(key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		*l.errs = append(*l.errs, fmt.Errorf("%s: invalid boolean %q", key, v))
+		return def
+	}
+	return b
+}
+
+func (l loader) getDuration(key string, def time.Duration) time.Duration {
+	v, ok := l.lookup(key)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		*l.errs = append(*l.errs, fmt.Errorf("%s: invalid duration %q", key, v))
+		return def
+	}
+	return d
+}
+
+// getRateLimit parses a budget such as "10/15m"; "0" or "off" disables it
+func (l loader) getRateLimit(key string, def RateLimit) RateLimit {
+	v, ok := l.lookup(key)
+	if !ok {
+		return def
+	}
+	if v == "0" || strings.EqualFold(v, "off") {
+		return RateLimit{}
+	}
+
+	limit, window, found := strings.Cut(v, "/")
+	n, err := strconv.Atoi(strings.TrimSpace(limit))
+	d, derr := time.ParseDuration(strings.TrimSpace(window))
+	if !found || err != nil || derr != nil || n < 0 || d <= 0 {
+		*l.errs = append(*l.errs, fmt.Errorf("%s: invalid rate limit %q, expected e.g. 10/15m", key, v))
+		return def
+	}
+	return RateLimit{Limit: n, Window: d}
+}
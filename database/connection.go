@@ -1,9 +1,10 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"os"
+	"vcm-medical-platform/config"
 	"vcm-medical-platform/models"
 
 	"gorm.io/driver/postgres"
@@ -13,56 +14,59 @@ import (
 
 var DB *gorm.DB
 
-func Connect() error {
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
-	sslmode := os.Getenv("DB_SSLMODE")
-
-	if host == "" {
-		host = "localhost"
-	}
-	if port == "" {
-		port = "5432"
-	}
-	if sslmode == "" {
-		sslmode = "disable"
+func Connect(cfg config.DatabaseConfig) error {
+	logLevel := logger.Warn
+	if cfg.LogQueries {
+		logLevel = logger.Info
 	}
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", 
-		host, port, user, password, dbname, sslmode)
-
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+	DB, err = gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
 	})
 
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return fmt.Errorf("failed to access database pool: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
 	log.Println("✅ Database connection established")
 	return nil
 }
 
+// Close releases the connection pool once in-flight queries have finished
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return fmt.Errorf("failed to access database pool: %w", err)
+	}
+
+	if err := sqlDB.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	log.Println("✅ Database connection closed")
+	return nil
+}
+
+// Migrate applies pending versioned SQL migrations from database/migrations
 func Migrate() error {
 	if DB == nil {
 		return fmt.Errorf("database connection not established")
 	}
 
-	// Auto migrate the schema
-	err := DB.AutoMigrate(
-		&models.UserType{},
-		&models.User{},
-		&models.Country{},
-		&models.State{},
-		&models.City{},
-		&models.District{},
-	)
-
-	if err != nil {
+	if err := MigrateUp(context.Background()); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
@@ -98,6 +102,9 @@ func SeedData() error {
 		}
 	}
 
+	// Seed one role per user type and the permission catalog
+	seedRBAC(userTypes)
+
 	// Seed basic countries
 	countries := []models.Country{
 		{CdCountry: 1, CountryName: "United States", CountryAbbr: "US"},
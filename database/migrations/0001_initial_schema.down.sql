@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS chat_message;
+DROP TABLE IF EXISTS chat_room;
+DROP TABLE IF EXISTS "order";
+DROP TABLE IF EXISTS appointments;
+DROP TABLE IF EXISTS af_psoriasis;
+DROP TABLE IF EXISTS district;
+DROP TABLE IF EXISTS city;
+DROP TABLE IF EXISTS state;
+DROP TABLE IF EXISTS country;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS usertype;
@@ -0,0 +1,146 @@
+-- Initial schema, matching the GORM models in models/user.go plus the
+-- patient-facing tables that previously lived only in schema.sql.
+-- Statements are idempotent so databases created by AutoMigrate or the
+-- old schema.sql can be adopted without data loss.
+
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+CREATE EXTENSION IF NOT EXISTS "pgcrypto";
+
+-- User types
+CREATE TABLE IF NOT EXISTS usertype (
+    usertype           SMALLINT PRIMARY KEY,
+    usertype_name      VARCHAR(64) NOT NULL
+);
+
+-- Users
+CREATE TABLE IF NOT EXISTS users (
+    cd_user            SERIAL PRIMARY KEY,
+    user_status        VARCHAR(24) NOT NULL DEFAULT 'Registered',
+    ty_user            SMALLINT NOT NULL REFERENCES usertype(usertype),
+    subtype_user       SMALLINT NOT NULL DEFAULT 0,
+    email              VARCHAR(320) NOT NULL,
+    password           VARCHAR(60) NOT NULL,
+    otp_code           VARCHAR(6) NOT NULL DEFAULT '',
+    otp_created_at     TIMESTAMP WITH TIME ZONE DEFAULT '1970-01-01 00:00:01'::timestamp,
+    first_name         VARCHAR(64) NOT NULL DEFAULT '',
+    last_name          VARCHAR(64) NOT NULL DEFAULT '',
+    gender             VARCHAR(16) NOT NULL DEFAULT 'Other',
+    phone_number       VARCHAR(30) NOT NULL DEFAULT '',
+    date_of_birth      DATE NOT NULL DEFAULT '1900-01-01',
+    wechat_id          VARCHAR(64) NOT NULL DEFAULT '',
+    languages          VARCHAR(128) NOT NULL DEFAULT '',
+    occupation         VARCHAR(128) NOT NULL DEFAULT '',
+    religion           VARCHAR(64) NOT NULL DEFAULT '',
+    height_cm          SMALLINT NOT NULL DEFAULT 0,
+    weight_kg          SMALLINT NOT NULL DEFAULT 0,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Columns schema.sql never had
+ALTER TABLE users ADD COLUMN IF NOT EXISTS marital_status VARCHAR(24) NOT NULL DEFAULT 'Single';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS no_children    SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS cd_country     INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS cd_state       INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS cd_city        INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS cd_district    INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS cd_street      INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS street_address VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS postal_code    VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at     TIMESTAMP WITH TIME ZONE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_unique ON users(email);
+CREATE INDEX IF NOT EXISTS idx_users_type ON users(ty_user);
+CREATE INDEX IF NOT EXISTS idx_users_status ON users(user_status);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at);
+
+-- Locations
+CREATE TABLE IF NOT EXISTS country (
+    cd_country         INTEGER PRIMARY KEY,
+    country_name       VARCHAR(64) NOT NULL,
+    country_abbr       VARCHAR(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS state (
+    cd_country         INTEGER NOT NULL,
+    cd_state           INTEGER NOT NULL,
+    state_name         VARCHAR(64) NOT NULL,
+    state_abbr         VARCHAR(16) NOT NULL,
+    PRIMARY KEY (cd_country, cd_state)
+);
+
+CREATE TABLE IF NOT EXISTS city (
+    cd_country         INTEGER NOT NULL,
+    cd_state           INTEGER NOT NULL,
+    cd_city            INTEGER NOT NULL,
+    city_name          VARCHAR(64) NOT NULL,
+    city_abbr          VARCHAR(16) NOT NULL,
+    PRIMARY KEY (cd_country, cd_state, cd_city)
+);
+
+CREATE TABLE IF NOT EXISTS district (
+    cd_country         INTEGER NOT NULL,
+    cd_state           INTEGER NOT NULL,
+    cd_city            INTEGER NOT NULL,
+    cd_district        INTEGER NOT NULL,
+    district_name      VARCHAR(64) NOT NULL,
+    district_abbr      VARCHAR(16) NOT NULL,
+    PRIMARY KEY (cd_country, cd_state, cd_city, cd_district)
+);
+
+-- Assessments
+CREATE TABLE IF NOT EXISTS af_psoriasis (
+    cd_assessment      SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user),
+    status             SMALLINT NOT NULL DEFAULT 0,
+    cd_disease         SMALLINT NOT NULL DEFAULT 1,
+    cd_product         SMALLINT NOT NULL DEFAULT 1,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Appointments
+CREATE TABLE IF NOT EXISTS appointments (
+    cd_appointment     SERIAL PRIMARY KEY,
+    cd_doctor          INTEGER NOT NULL REFERENCES users(cd_user),
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user),
+    appointment_date   DATE NOT NULL DEFAULT CURRENT_DATE,
+    appointment_time   TIME NOT NULL DEFAULT '09:00:00',
+    duration_minutes   SMALLINT NOT NULL DEFAULT 30,
+    status             VARCHAR(32) NOT NULL DEFAULT 'scheduled',
+    notes              TEXT NOT NULL DEFAULT '',
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Orders
+CREATE TABLE IF NOT EXISTS "order" (
+    cd_order           SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user),
+    total_amount       DECIMAL(10,2) NOT NULL DEFAULT 0.00,
+    status             VARCHAR(32) NOT NULL DEFAULT 'pending',
+    order_reference    VARCHAR(64) NOT NULL DEFAULT '',
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Chat
+CREATE TABLE IF NOT EXISTS chat_room (
+    cd_chat_room       SERIAL PRIMARY KEY,
+    cd_patient         INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    cd_staff           INTEGER DEFAULT NULL REFERENCES users(cd_user) ON DELETE SET NULL,
+    cd_room_type       SMALLINT NOT NULL DEFAULT 1,
+    status             VARCHAR(32) NOT NULL DEFAULT 'waiting',
+    subject            VARCHAR(256) NOT NULL DEFAULT '',
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS chat_message (
+    cd_message         SERIAL PRIMARY KEY,
+    cd_chat_room       INTEGER NOT NULL REFERENCES chat_room(cd_chat_room) ON DELETE CASCADE,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    content            TEXT NOT NULL,
+    is_read            BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
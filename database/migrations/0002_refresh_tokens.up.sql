@@ -0,0 +1,13 @@
+CREATE TABLE refresh_tokens (
+    id                 SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    family_id          VARCHAR(36) NOT NULL,
+    token_hash         VARCHAR(64) NOT NULL UNIQUE,
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at            TIMESTAMP WITH TIME ZONE,
+    revoked_at         TIMESTAMP WITH TIME ZONE,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens(cd_user);
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id);
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS otp_code VARCHAR(6) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS otp_created_at TIMESTAMP WITH TIME ZONE DEFAULT '1970-01-01 00:00:01'::timestamp;
+
+DROP TABLE IF EXISTS otp_throttle;
+DROP TABLE IF EXISTS otp_challenge;
@@ -0,0 +1,28 @@
+CREATE TABLE otp_challenge (
+    id                 SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    purpose            VARCHAR(24) NOT NULL,
+    code_hash          VARCHAR(64) NOT NULL,
+    attempts           SMALLINT NOT NULL DEFAULT 0,
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    consumed_at        TIMESTAMP WITH TIME ZONE,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_otp_challenge_user_purpose ON otp_challenge(cd_user, purpose);
+
+CREATE TABLE otp_throttle (
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    purpose            VARCHAR(24) NOT NULL,
+    failed_attempts    SMALLINT NOT NULL DEFAULT 0,
+    lockout_count      SMALLINT NOT NULL DEFAULT 0,
+    locked_until       TIMESTAMP WITH TIME ZONE,
+    last_sent_at       TIMESTAMP WITH TIME ZONE,
+    updated_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (cd_user, purpose)
+);
+
+-- Plaintext codes are no longer kept on the user row; outstanding codes
+-- are dropped and users simply request a new one
+ALTER TABLE users DROP COLUMN IF EXISTS otp_code;
+ALTER TABLE users DROP COLUMN IF EXISTS otp_created_at;
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_users_status;
+
+DROP TABLE IF EXISTS user_status_history;
@@ -0,0 +1,14 @@
+CREATE TABLE user_status_history (
+    id                 SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    from_status        VARCHAR(24) NOT NULL,
+    to_status          VARCHAR(24) NOT NULL,
+    cd_actor           INTEGER REFERENCES users(cd_user) ON DELETE SET NULL,
+    reason             VARCHAR(255) NOT NULL DEFAULT '',
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_status_history_user ON user_status_history(cd_user);
+
+ALTER TABLE users ADD CONSTRAINT chk_users_status CHECK (user_status IN
+    ('Registered', 'EmailVerified', 'Active', 'Suspended', 'Deactivated', 'Deleted'));
@@ -0,0 +1,16 @@
+CREATE TABLE invitations (
+    id                 SERIAL PRIMARY KEY,
+    email              VARCHAR(320) NOT NULL,
+    ty_user            SMALLINT NOT NULL REFERENCES usertype(usertype),
+    subtype_user       SMALLINT NOT NULL DEFAULT 0,
+    token_hash         VARCHAR(64) NOT NULL,
+    invited_by         INTEGER NOT NULL REFERENCES users(cd_user),
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at        TIMESTAMP WITH TIME ZONE,
+    accepted_by        INTEGER REFERENCES users(cd_user) ON DELETE SET NULL,
+    revoked_at         TIMESTAMP WITH TIME ZONE,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_invitations_email ON invitations(email);
@@ -0,0 +1,27 @@
+CREATE TABLE roles (
+    id                 SERIAL PRIMARY KEY,
+    usertype           SMALLINT NOT NULL UNIQUE REFERENCES usertype(usertype) ON DELETE CASCADE,
+    name               VARCHAR(64) NOT NULL,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE permissions (
+    id                 SERIAL PRIMARY KEY,
+    code               VARCHAR(64) NOT NULL UNIQUE,
+    description        VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions (
+    role_id            INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id      INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE user_permission_overrides (
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    permission_id      INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    granted            BOOLEAN NOT NULL,
+    granted_by         INTEGER REFERENCES users(cd_user) ON DELETE SET NULL,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (cd_user, permission_id)
+);
@@ -0,0 +1,18 @@
+CREATE TABLE user_totp (
+    cd_user            INTEGER PRIMARY KEY REFERENCES users(cd_user) ON DELETE CASCADE,
+    secret_enc         VARCHAR(255) NOT NULL,
+    confirmed_at       TIMESTAMP WITH TIME ZONE,
+    last_used_step     BIGINT NOT NULL DEFAULT 0,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE mfa_recovery_codes (
+    id                 SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    code_hash          VARCHAR(64) NOT NULL,
+    used_at            TIMESTAMP WITH TIME ZONE,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_mfa_recovery_codes_user ON mfa_recovery_codes(cd_user);
@@ -0,0 +1,10 @@
+CREATE TABLE jwt_signing_keys (
+    kid                VARCHAR(36) PRIMARY KEY,
+    algorithm          VARCHAR(16) NOT NULL,
+    private_key_enc    TEXT NOT NULL,
+    public_key         VARCHAR(64) NOT NULL,
+    not_before         TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    retires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,28 @@
+CREATE TABLE sessions (
+    id                 VARCHAR(36) PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    device             VARCHAR(100) NOT NULL DEFAULT '',
+    user_agent         VARCHAR(512) NOT NULL DEFAULT '',
+    ip_address         VARCHAR(45) NOT NULL DEFAULT '',
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at         TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_sessions_user ON sessions(cd_user);
+
+-- Every existing refresh token family becomes a session
+INSERT INTO sessions (id, cd_user, created_at, last_seen_at, expires_at, revoked_at)
+SELECT family_id,
+       MIN(cd_user),
+       MIN(created_at),
+       MAX(created_at),
+       MAX(expires_at),
+       CASE WHEN BOOL_AND(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY family_id;
+
+ALTER TABLE refresh_tokens
+    ADD CONSTRAINT fk_refresh_tokens_session
+    FOREIGN KEY (family_id) REFERENCES sessions(id) ON DELETE CASCADE;
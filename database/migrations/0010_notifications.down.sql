@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS notification_deliveries;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS notification_channel,
+    DROP COLUMN IF EXISTS wechat_openid;
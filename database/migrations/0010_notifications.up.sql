@@ -0,0 +1,19 @@
+ALTER TABLE users
+    ADD COLUMN wechat_openid VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN notification_channel VARCHAR(16) NOT NULL DEFAULT ''
+        CHECK (notification_channel IN ('', 'email', 'sms', 'wechat'));
+
+CREATE TABLE notification_deliveries (
+    id                   SERIAL PRIMARY KEY,
+    cd_user              INTEGER REFERENCES users(cd_user) ON DELETE SET NULL,
+    channel              VARCHAR(16) NOT NULL,
+    recipient            VARCHAR(320) NOT NULL,
+    template             VARCHAR(32) NOT NULL,
+    status               VARCHAR(16) NOT NULL CHECK (status IN ('pending', 'sent', 'failed')),
+    provider_message_id  VARCHAR(128) NOT NULL DEFAULT '',
+    error                VARCHAR(512) NOT NULL DEFAULT '',
+    created_at           TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    sent_at              TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_notification_deliveries_user ON notification_deliveries(cd_user, created_at);
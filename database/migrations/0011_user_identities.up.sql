@@ -0,0 +1,16 @@
+CREATE TABLE user_identities (
+    id                 SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    provider           VARCHAR(16) NOT NULL,
+    subject            VARCHAR(128) NOT NULL,
+    union_id           VARCHAR(128) NOT NULL DEFAULT '',
+    display_name       VARCHAR(128) NOT NULL DEFAULT '',
+    avatar_url         VARCHAR(512) NOT NULL DEFAULT '',
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_login_at      TIMESTAMP WITH TIME ZONE,
+    UNIQUE (provider, subject),
+    -- One account per provider per user
+    UNIQUE (cd_user, provider)
+);
+
+CREATE INDEX idx_user_identities_union ON user_identities(provider, union_id) WHERE union_id <> '';
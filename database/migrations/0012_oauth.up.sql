@@ -0,0 +1,52 @@
+CREATE TABLE oauth_clients (
+    client_id          VARCHAR(64) PRIMARY KEY,
+    -- Empty for public clients, which authenticate with PKCE alone
+    secret_hash        VARCHAR(64) NOT NULL DEFAULT '',
+    name               VARCHAR(128) NOT NULL,
+    logo_url           VARCHAR(512) NOT NULL DEFAULT '',
+    -- Space-separated, like OAuth scope strings
+    redirect_uris      TEXT NOT NULL,
+    scopes             VARCHAR(255) NOT NULL,
+    created_by         INTEGER REFERENCES users(cd_user) ON DELETE SET NULL,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE oauth_consents (
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    client_id          VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    scopes             VARCHAR(255) NOT NULL,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (cd_user, client_id)
+);
+
+CREATE TABLE oauth_authorization_codes (
+    id                 SERIAL PRIMARY KEY,
+    code_hash          VARCHAR(64) NOT NULL UNIQUE,
+    client_id          VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    redirect_uri       VARCHAR(512) NOT NULL,
+    scopes             VARCHAR(255) NOT NULL,
+    nonce              VARCHAR(255) NOT NULL DEFAULT '',
+    code_challenge     VARCHAR(128) NOT NULL,
+    auth_time          TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at            TIMESTAMP WITH TIME ZONE,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE oauth_access_tokens (
+    id                 SERIAL PRIMARY KEY,
+    token_hash         VARCHAR(64) NOT NULL UNIQUE,
+    client_id          VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    code_id            INTEGER REFERENCES oauth_authorization_codes(id) ON DELETE SET NULL,
+    scopes             VARCHAR(255) NOT NULL,
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at         TIMESTAMP WITH TIME ZONE,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_oauth_access_tokens_user ON oauth_access_tokens(cd_user, client_id);
+CREATE INDEX idx_oauth_access_tokens_code ON oauth_access_tokens(code_id);
@@ -0,0 +1,17 @@
+CREATE TABLE api_keys (
+    id                 SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    name               VARCHAR(100) NOT NULL,
+    -- Leading characters of the key, kept so owners can recognise it
+    prefix             VARCHAR(16) NOT NULL,
+    key_hash           VARCHAR(64) NOT NULL UNIQUE,
+    -- Space-separated permission codes the key may exercise
+    scopes             VARCHAR(1024) NOT NULL DEFAULT '',
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_used_at       TIMESTAMP WITH TIME ZONE,
+    last_used_ip       VARCHAR(45) NOT NULL DEFAULT '',
+    revoked_at         TIMESTAMP WITH TIME ZONE,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_user ON api_keys(cd_user);
@@ -0,0 +1,10 @@
+-- Fixed-window counters behind the sliding-window rate limiter
+CREATE TABLE rate_limit_counters (
+    bucket             VARCHAR(160) NOT NULL,
+    window_start       TIMESTAMP WITH TIME ZONE NOT NULL,
+    count              INTEGER NOT NULL,
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (bucket, window_start)
+);
+
+CREATE INDEX idx_rate_limit_counters_expires ON rate_limit_counters(expires_at);
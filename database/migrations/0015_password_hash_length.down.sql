@@ -0,0 +1,2 @@
+-- Only safe once no argon2id hashes remain
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(60);
@@ -0,0 +1,2 @@
+-- argon2id hashes in PHC format are longer than bcrypt's 60 characters
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(255);
@@ -0,0 +1,21 @@
+CREATE TABLE data_requests (
+    id                 SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    -- export or erasure
+    kind               VARCHAR(16) NOT NULL,
+    status             VARCHAR(16) NOT NULL DEFAULT 'pending',
+    -- NULL when the user asked for it themselves
+    requested_by       INTEGER REFERENCES users(cd_user) ON DELETE SET NULL,
+    reason             VARCHAR(255) NOT NULL DEFAULT '',
+    scheduled_for      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at       TIMESTAMP WITH TIME ZONE,
+    -- Zip archive of an export, cleared once it expires
+    archive            BYTEA,
+    archive_size       INTEGER NOT NULL DEFAULT 0,
+    expires_at         TIMESTAMP WITH TIME ZONE,
+    error              VARCHAR(512) NOT NULL DEFAULT '',
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_data_requests_user ON data_requests(cd_user, kind);
+CREATE INDEX idx_data_requests_due ON data_requests(status, scheduled_for);
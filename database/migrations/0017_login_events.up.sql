@@ -0,0 +1,22 @@
+CREATE TABLE login_events (
+    id                 BIGSERIAL PRIMARY KEY,
+    -- NULL when the attempt named an account that does not exist
+    cd_user            INTEGER REFERENCES users(cd_user) ON DELETE CASCADE,
+    email              VARCHAR(320) NOT NULL DEFAULT '',
+    -- password, otp, mfa, wechat, refresh or session_started
+    event              VARCHAR(24) NOT NULL,
+    outcome            VARCHAR(16) NOT NULL,
+    reason             VARCHAR(64) NOT NULL DEFAULT '',
+    ip_address         VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent         VARCHAR(512) NOT NULL DEFAULT '',
+    device             VARCHAR(100) NOT NULL DEFAULT '',
+    country            VARCHAR(2) NOT NULL DEFAULT '',
+    session_id         VARCHAR(36) NOT NULL DEFAULT '',
+    new_device         BOOLEAN NOT NULL DEFAULT FALSE,
+    new_country        BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_login_events_user ON login_events(cd_user, created_at);
+CREATE INDEX idx_login_events_email ON login_events(email, created_at);
+CREATE INDEX idx_login_events_ip ON login_events(ip_address, created_at);
@@ -0,0 +1,21 @@
+CREATE TABLE contact_changes (
+    id                 SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    -- email or phone
+    kind               VARCHAR(16) NOT NULL,
+    old_value          VARCHAR(320) NOT NULL DEFAULT '',
+    new_value          VARCHAR(320) NOT NULL,
+    status             VARCHAR(16) NOT NULL DEFAULT 'pending',
+    -- The pending change lapses with its verification code
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    completed_at       TIMESTAMP WITH TIME ZONE,
+    -- Link sent to the previous address to reverse a change the user did not make
+    undo_token_hash    VARCHAR(64),
+    undo_expires_at    TIMESTAMP WITH TIME ZONE,
+    undone_at          TIMESTAMP WITH TIME ZONE,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_contact_changes_user ON contact_changes(cd_user, kind, status);
+CREATE UNIQUE INDEX idx_contact_changes_undo_token ON contact_changes(undo_token_hash);
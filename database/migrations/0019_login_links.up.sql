@@ -0,0 +1,15 @@
+CREATE TABLE login_links (
+    id                 SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    -- Hash of the signed link; the emailed code lives in otp_challenges
+    token_hash         VARCHAR(64) NOT NULL,
+    -- Hash of the device token handed to the browser that asked for the link
+    device_hash        VARCHAR(64) NOT NULL,
+    ip_address         VARCHAR(45) NOT NULL DEFAULT '',
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    consumed_at        TIMESTAMP WITH TIME ZONE,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_login_links_user ON login_links(cd_user, consumed_at);
+CREATE UNIQUE INDEX idx_login_links_token ON login_links(token_hash);
@@ -0,0 +1,34 @@
+CREATE TABLE webauthn_credentials (
+    id                 SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    credential_id      BYTEA NOT NULL,
+    public_key         BYTEA NOT NULL,
+    attestation_type   VARCHAR(32) NOT NULL DEFAULT '',
+    -- Comma-separated transports reported by the authenticator (usb, nfc, ble, internal, hybrid)
+    transports         VARCHAR(128) NOT NULL DEFAULT '',
+    aaguid             BYTEA,
+    -- Last signature counter seen; a counter that goes backwards suggests a cloned key
+    sign_count         BIGINT NOT NULL DEFAULT 0,
+    clone_warning      BOOLEAN NOT NULL DEFAULT FALSE,
+    user_verified      BOOLEAN NOT NULL DEFAULT FALSE,
+    backup_eligible    BOOLEAN NOT NULL DEFAULT FALSE,
+    backup_state       BOOLEAN NOT NULL DEFAULT FALSE,
+    name               VARCHAR(64) NOT NULL DEFAULT '',
+    last_used_at       TIMESTAMP WITH TIME ZONE,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webauthn_credentials_user ON webauthn_credentials(cd_user);
+CREATE UNIQUE INDEX idx_webauthn_credentials_credential ON webauthn_credentials(credential_id);
+
+-- Challenges of registration and login ceremonies in progress; each is used once
+CREATE TABLE webauthn_ceremonies (
+    id                 VARCHAR(36) PRIMARY KEY,
+    cd_user            INTEGER REFERENCES users(cd_user) ON DELETE CASCADE,
+    kind               VARCHAR(16) NOT NULL,
+    session_data       TEXT NOT NULL,
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webauthn_ceremonies_expires ON webauthn_ceremonies(expires_at);
@@ -0,0 +1,34 @@
+-- Versioned legal documents. Translations of one version share kind, country
+-- and version; cd_country 0 applies wherever no country-specific version exists.
+CREATE TABLE consent_documents (
+    id                 SERIAL PRIMARY KEY,
+    kind               VARCHAR(32) NOT NULL,
+    cd_country         INTEGER NOT NULL DEFAULT 0,
+    language           VARCHAR(8) NOT NULL,
+    version            INTEGER NOT NULL CHECK (version > 0),
+    title              VARCHAR(255) NOT NULL,
+    content            TEXT NOT NULL DEFAULT '',
+    url                VARCHAR(512) NOT NULL DEFAULT '',
+    -- Takes effect at this time; users must accept required documents from then on
+    published_at       TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_by         INTEGER REFERENCES users(cd_user) ON DELETE SET NULL,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_consent_documents_version ON consent_documents(kind, cd_country, language, version);
+
+-- Every acceptance and withdrawal, newest row per kind is the user's current choice
+CREATE TABLE user_consents (
+    id                 SERIAL PRIMARY KEY,
+    cd_user            INTEGER NOT NULL REFERENCES users(cd_user) ON DELETE CASCADE,
+    document_id        INTEGER NOT NULL REFERENCES consent_documents(id),
+    kind               VARCHAR(32) NOT NULL,
+    cd_country         INTEGER NOT NULL DEFAULT 0,
+    version            INTEGER NOT NULL,
+    granted            BOOLEAN NOT NULL,
+    ip_address         VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent         VARCHAR(512) NOT NULL DEFAULT '',
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_consents_user ON user_consents(cd_user, kind, id);
@@ -0,0 +1,3 @@
+-- Numbers typed into the profile were never confirmed, so they start unverified
+ALTER TABLE users
+    ADD COLUMN phone_verified_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"log"
+	"vcm-medical-platform/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// permissionSeed is a permission and the user types granted it by default
+type permissionSeed struct {
+	Code        string
+	Description string
+	UserTypes   []int
+}
+
+// defaultPermissions is the catalog of permissions known to the code. Default
+// grants are applied only when a permission is first created, so changes an
+// admin makes afterwards are never overwritten on restart.
+var defaultPermissions = []permissionSeed{
+	{models.PermUsersRead, "View user accounts and their history",
+		[]int{models.UserTypeOperator, models.UserTypeAdmin, models.UserTypeSuperAdmin}},
+	{models.PermUsersStatusManage, "Suspend, reactivate and delete user accounts",
+		[]int{models.UserTypeAdmin, models.UserTypeSuperAdmin}},
+	{models.PermUsersSessionsManage, "Log users out of their sessions",
+		[]int{models.UserTypeAdmin, models.UserTypeSuperAdmin}},
+	{models.PermUsersAPIKeysManage, "Revoke users' API keys",
+		[]int{models.UserTypeAdmin, models.UserTypeSuperAdmin}},
+	{models.PermUsersDataExport, "Export a user's personal data",
+		[]int{models.UserTypeAdmin, models.UserTypeSuperAdmin}},
+	{models.PermUsersDataErase, "Erase a user's personal data",
+		[]int{models.UserTypeSuperAdmin}},
+	{models.PermAPIKeysManage, "Create API keys for system integrations",
+		[]int{models.UserTypeSalesChannel, models.UserTypeDistributor, models.UserTypeAdmin, models.UserTypeSuperAdmin}},
+	{models.PermInvitationsManage, "Invite doctors and staff",
+		[]int{models.UserTypeAdmin, models.UserTypeSuperAdmin}},
+	{models.PermRBACManage, "Manage roles, permissions and user overrides",
+		[]int{models.UserTypeSuperAdmin}},
+	{models.PermOAuthClientsManage, "Register partner portals for single sign-on",
+		[]int{models.UserTypeSuperAdmin}},
+	{models.PermConsentsManage, "Publish terms, privacy and consent documents",
+		[]int{models.UserTypeSuperAdmin}},
+}
+
+// seedRBAC creates one role per user type and any missing permissions
+func seedRBAC(userTypes []models.UserType) {
+	roleIDs := map[int]uint{}
+	for _, userType := range userTypes {
+		role := models.Role{UserType: userType.UserType, Name: userType.UserTypeName}
+		if err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&role).Error; err != nil {
+			log.Printf("Error creating role for user type %d: %v", userType.UserType, err)
+			continue
+		}
+		if err := DB.Where("usertype = ?", userType.UserType).First(&role).Error; err != nil {
+			log.Printf("Error loading role for user type %d: %v", userType.UserType, err)
+			continue
+		}
+		roleIDs[userType.UserType] = role.ID
+	}
+
+	for _, seed := range defaultPermissions {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			permission := models.Permission{Code: seed.Code, Description: seed.Description}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&permission)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			for _, userType := range seed.UserTypes {
+				roleID, ok := roleIDs[userType]
+				if !ok {
+					continue
+				}
+				if err := tx.Exec(`INSERT INTO role_permissions (role_id, permission_id) VALUES (?, ?)
+					ON CONFLICT DO NOTHING`, roleID, permission.ID).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error seeding permission %s: %v", seed.Code, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"time"
+	"vcm-medical-platform/config"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// signingKeyLockKey serialises key rotation so replicas never create
+// competing keys for the same period
+const signingKeyLockKey int64 = 0x56434d4a574b5953 // "VCMJWKYS"
+
+// RefreshSigningKeys rotates the JWT key ring when due and loads it into utils.
+// A key signs for cfg.KeyRotation; its successor is created a quarter of that
+// period early so it is published in the JWKS before it starts signing, and
+// each key keeps verifying for tokenTTL, the longest lifetime of any token it
+// signed, after it stops signing.
+func RefreshSigningKeys(cfg config.JWTConfig, tokenTTL time.Duration) error {
+	var stored []models.SigningKey
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", signingKeyLockKey).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Where("retires_at <= ?", now).Delete(&models.SigningKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Order("not_before").Find(&stored).Error; err != nil {
+			return err
+		}
+
+		var latest *models.SigningKey
+		active := false
+		for i := range stored {
+			if !now.Before(stored[i].NotBefore) && now.Before(stored[i].ExpiresAt) {
+				active = true
+			}
+			latest = &stored[i]
+		}
+
+		if !active {
+			key, err := createSigningKey(tx, cfg, tokenTTL, now)
+			if err != nil {
+				return err
+			}
+			stored = append(stored, *key)
+			latest = key
+		}
+
+		if latest.ExpiresAt.Sub(now) <= cfg.KeyRotation/4 {
+			key, err := createSigningKey(tx, cfg, tokenTTL, latest.ExpiresAt)
+			if err != nil {
+				return err
+			}
+			stored = append(stored, *key)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rotate signing keys: %w", err)
+	}
+
+	keys := make([]utils.SigningKey, 0, len(stored))
+	for _, k := range stored {
+		seed, err := utils.DecryptSecret(k.PrivateKeyEnc)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt signing key %s: %w", k.Kid, err)
+		}
+		raw, err := base64.StdEncoding.DecodeString(seed)
+		if err != nil || len(raw) != ed25519.SeedSize {
+			return fmt.Errorf("signing key %s is corrupt", k.Kid)
+		}
+
+		keys = append(keys, utils.SigningKey{
+			ID:         k.Kid,
+			PrivateKey: ed25519.NewKeyFromSeed(raw),
+			NotBefore:  k.NotBefore,
+			ExpiresAt:  k.ExpiresAt,
+			RetiresAt:  k.RetiresAt,
+		})
+	}
+
+	utils.SetSigningKeys(keys)
+	return nil
+}
+
+// RunSigningKeyRotation refreshes the key ring every interval until the process exits
+func RunSigningKeyRotation(cfg config.JWTConfig, tokenTTL, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := RefreshSigningKeys(cfg, tokenTTL); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+	}
+}
+
+func createSigningKey(tx *gorm.DB, cfg config.JWTConfig, tokenTTL time.Duration, notBefore time.Time) (*models.SigningKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	seedEnc, err := utils.EncryptSecret(base64.StdEncoding.EncodeToString(private.Seed()))
+	if err != nil {
+		return nil, err
+	}
+
+	key := models.SigningKey{
+		Kid:           uuid.NewString(),
+		Algorithm:     "EdDSA",
+		PrivateKeyEnc: seedEnc,
+		PublicKey:     base64.StdEncoding.EncodeToString(public),
+		NotBefore:     notBefore,
+		ExpiresAt:     notBefore.Add(cfg.KeyRotation),
+		RetiresAt:     notBefore.Add(cfg.KeyRotation + tokenTTL),
+	}
+	if err := tx.Create(&key).Error; err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔑 Created JWT signing key %s (active from %s)", key.Kid, key.NotBefore.Format(time.RFC3339))
+	return &key, nil
+}
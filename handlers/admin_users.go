@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type UpdateUserStatusRequest struct {
+	Status string `json:"status" validate:"required"`
+	Reason string `json:"reason" validate:"required"`
+}
+
+// UpdateUserStatus - Move a user to another lifecycle state (admin)
+func UpdateUserStatus(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var req UpdateUserStatusRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	if !models.IsValidUserStatus(req.Status) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Unknown user status",
+		})
+	}
+	// Deleting keeps the personal data; erasure removes it and then marks the account deleted
+	if req.Status == models.UserStatusDeleted {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Use the erasure endpoint to delete an account",
+		})
+	}
+
+	actorID := c.Locals("userID").(uint)
+	if uint(userID) == actorID {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "You cannot change your own account status",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", userID).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if errResp := outrankedResponse(c, &user); errResp != nil {
+		return errResp()
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := user.TransitionStatus(tx, req.Status, &actorID, req.Reason); err != nil {
+			return err
+		}
+
+		// Anyone leaving the active state loses their sessions immediately
+		switch req.Status {
+		case models.UserStatusSuspended, models.UserStatusDeactivated:
+			return revokeAllSessions(tx, user.CdUser)
+		}
+		return nil
+	})
+	if errors.Is(err, models.ErrInvalidStatusTransition) || errors.Is(err, models.ErrStatusChanged) {
+		return c.Status(409).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		log.Printf("Error updating user status: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update user status",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "User status updated",
+		"user": fiber.Map{
+			"id":     user.CdUser,
+			"email":  user.Email,
+			"status": user.UserStatus,
+		},
+	})
+}
+
+// outrankedResponse refuses to let an admin act on a user of the same or a
+// higher user type. Super Admins may act on anyone. It returns nil when allowed.
+func outrankedResponse(c *fiber.Ctx, target *models.User) func() error {
+	actorType := c.Locals("userType").(int)
+	if actorType == models.UserTypeSuperAdmin || target.TyUser < actorType {
+		return nil
+	}
+
+	return func() error {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "You cannot manage users at or above your own user type",
+		})
+	}
+}
+
+// GetUserStatusHistory - List lifecycle transitions for a user (admin)
+func GetUserStatusHistory(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var history []models.UserStatusHistory
+	if err := database.DB.Where("cd_user = ?", userID).Order("created_at DESC").Find(&history).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch status history",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"history": history,
+	})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newAdminTestApp(actor *models.User) *fiber.App {
+	app := fiber.New()
+	app.Put("/admin/users/:id/status", asUser(actor), UpdateUserStatus)
+	return app
+}
+
+func updateStatus(t *testing.T, app *fiber.App, target *models.User, status string) int {
+	t.Helper()
+
+	code, _ := sendJSON(t, app, fiber.MethodPut, fmt.Sprintf("/admin/users/%d/status", target.CdUser), fiber.Map{
+		"status": status,
+		"reason": "test",
+	})
+	return code
+}
+
+func reloadStatus(t *testing.T, user *models.User) string {
+	t.Helper()
+
+	var stored models.User
+	if err := database.DB.Where("cd_user = ?", user.CdUser).First(&stored).Error; err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	return stored.UserStatus
+}
+
+func TestUpdateUserStatusRespectsRank(t *testing.T) {
+	setupTestDB(t)
+	superAdmin := createTestUser(t, "root@vcm.test", models.UserTypeSuperAdmin)
+	admin := createTestUser(t, "admin@vcm.test", models.UserTypeAdmin)
+	otherAdmin := createTestUser(t, "admin2@vcm.test", models.UserTypeAdmin)
+	patient := createTestUser(t, "patient@vcm.test", models.UserTypePatient)
+
+	adminApp := newAdminTestApp(admin)
+	for _, target := range []*models.User{superAdmin, otherAdmin} {
+		if code := updateStatus(t, adminApp, target, models.UserStatusSuspended); code != 403 {
+			t.Fatalf("admin suspending %s: status %d, want 403", target.Email, code)
+		}
+		if got := reloadStatus(t, target); got != models.UserStatusActive {
+			t.Fatalf("%s status = %s, want unchanged", target.Email, got)
+		}
+	}
+	if code := updateStatus(t, adminApp, patient, models.UserStatusSuspended); code != 200 {
+		t.Fatalf("admin suspending a patient: status %d, want 200", code)
+	}
+
+	if code := updateStatus(t, newAdminTestApp(superAdmin), admin, models.UserStatusSuspended); code != 200 {
+		t.Fatalf("super admin suspending an admin: status %d, want 200", code)
+	}
+}
+
+func TestUpdateUserStatusRefusesDelete(t *testing.T) {
+	setupTestDB(t)
+	superAdmin := createTestUser(t, "root@vcm.test", models.UserTypeSuperAdmin)
+	patient := createTestUser(t, "patient@vcm.test", models.UserTypePatient)
+
+	if code := updateStatus(t, newAdminTestApp(superAdmin), patient, models.UserStatusDeleted); code != 400 {
+		t.Fatalf("deleting through the status endpoint: status %d, want 400", code)
+	}
+	if got := reloadStatus(t, patient); got != models.UserStatusActive {
+		t.Fatalf("status = %s, want unchanged", got)
+	}
+}
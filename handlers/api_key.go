@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	apiKeyDefaultLifetimeDays = 90
+	apiKeyMaxLifetimeDays     = 365
+	// maxActiveAPIKeys caps live keys per user so forgotten integrations get cleaned up
+	maxActiveAPIKeys = 20
+)
+
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	// Scopes are permission codes; the key can never do more than its owner
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+func apiKeyResponse(key *models.APIKey) fiber.Map {
+	status := "active"
+	if key.RevokedAt != nil {
+		status = "revoked"
+	} else if time.Now().After(key.ExpiresAt) {
+		status = "expired"
+	}
+
+	return fiber.Map{
+		"id":           key.ID,
+		"name":         key.Name,
+		"prefix":       key.Prefix,
+		"scopes":       key.ScopeList(),
+		"status":       status,
+		"expires_at":   key.ExpiresAt,
+		"last_used_at": key.LastUsedAt,
+		"last_used_ip": key.LastUsedIP,
+		"revoked_at":   key.RevokedAt,
+		"created_at":   key.CreatedAt,
+	}
+}
+
+func listAPIKeys(c *fiber.Ctx, userID uint) error {
+	var keys []models.APIKey
+	if err := database.DB.Where("cd_user = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch API keys",
+		})
+	}
+
+	result := make([]fiber.Map, 0, len(keys))
+	for i := range keys {
+		result = append(result, apiKeyResponse(&keys[i]))
+	}
+
+	return c.JSON(fiber.Map{
+		"api_keys": result,
+	})
+}
+
+func revokeAPIKey(c *fiber.Ctx, userID uint, keyID int) error {
+	result := database.DB.Model(&models.APIKey{}).
+		Where("id = ? AND cd_user = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Error revoking API key: %v", result.Error)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to revoke API key",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "API key not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "API key revoked",
+	})
+}
+
+// CreateAPIKey - Create an API key for a system integration; the key is shown only once
+func CreateAPIKey(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	userType := c.Locals("userType").(int)
+
+	var req CreateAPIKeyRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Name is required and must be at most 100 characters",
+		})
+	}
+
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = apiKeyDefaultLifetimeDays
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > apiKeyMaxLifetimeDays {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "expires_in_days must be between 1 and 365",
+		})
+	}
+
+	held, err := models.EffectivePermissions(database.DB, userID, userType)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to check permissions",
+		})
+	}
+	scopeSet := map[string]bool{}
+	for _, scope := range req.Scopes {
+		if !containsString(held, scope) {
+			return c.Status(403).JSON(fiber.Map{
+				"error": "You cannot grant a permission you do not hold: " + scope,
+			})
+		}
+		scopeSet[scope] = true
+	}
+	scopes := make([]string, 0, len(scopeSet))
+	for scope := range scopeSet {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	var active int64
+	if err := database.DB.Model(&models.APIKey{}).
+		Where("cd_user = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Count(&active).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if active >= maxActiveAPIKeys {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Too many active API keys. Revoke one you no longer use.",
+		})
+	}
+
+	rawKey, prefix, keyHash, err := utils.GenerateAPIKey()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to generate API key",
+		})
+	}
+
+	key := models.APIKey{
+		CdUser:    userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   keyHash,
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: time.Now().AddDate(0, 0, req.ExpiresInDays),
+	}
+	if err := database.DB.Create(&key).Error; err != nil {
+		log.Printf("Error creating API key: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create API key",
+		})
+	}
+
+	log.Printf("🔑 User %d created API key %s", userID, key.Prefix)
+
+	return c.Status(201).JSON(fiber.Map{
+		"message": "API key created. Copy it now, it will not be shown again.",
+		"api_key": rawKey,
+		"key":     apiKeyResponse(&key),
+	})
+}
+
+// ListAPIKeys - List the current user's API keys
+func ListAPIKeys(c *fiber.Ctx) error {
+	return listAPIKeys(c, c.Locals("userID").(uint))
+}
+
+// RevokeAPIKey - Revoke one of the current user's API keys
+func RevokeAPIKey(c *fiber.Ctx) error {
+	keyID, err := c.ParamsInt("id")
+	if err != nil || keyID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid API key ID",
+		})
+	}
+
+	return revokeAPIKey(c, c.Locals("userID").(uint), keyID)
+}
+
+// ListUserAPIKeys - List a user's API keys (admin)
+func ListUserAPIKeys(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	return listAPIKeys(c, uint(userID))
+}
+
+// RevokeUserAPIKey - Revoke one of a user's API keys (admin)
+func RevokeUserAPIKey(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+	keyID, err := c.ParamsInt("keyId")
+	if err != nil || keyID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid API key ID",
+		})
+	}
+
+	log.Printf("🔒 User %d revoking API key %d of user %d", c.Locals("userID").(uint), keyID, userID)
+
+	return revokeAPIKey(c, uint(userID), keyID)
+}
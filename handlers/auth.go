@@ -5,6 +5,7 @@ import (
 	"time"
 	"vcm-medical-platform/database"
 	"vcm-medical-platform/models"
+	"vcm-medical-platform/notify"
 	"vcm-medical-platform/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,8 +14,12 @@ import (
 
 type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
-	UserType int    `json:"userType" validate:"required"`
+	Password string `json:"password" validate:"required"`
+	UserType int    `json:"userType" validate:"min=0"`
+	// InviteToken is required for user types that cannot self-register
+	InviteToken string `json:"invite_token"`
+	// AcceptedDocuments must cover every required consent document in force
+	AcceptedDocuments []uint `json:"accepted_documents"`
 }
 
 type LoginRequest struct {
@@ -29,33 +34,48 @@ type VerifyOTPRequest struct {
 
 type CompleteProfileRequest struct {
 	Email         string    `json:"email" validate:"required,email"`
-	FirstName     string    `json:"first_name" validate:"required"`
-	LastName      string    `json:"last_name" validate:"required"`
-	Gender        string    `json:"gender" validate:"required"`
-	DateOfBirth   time.Time `json:"date_of_birth" validate:"required"`
-	PhoneNumber   string    `json:"phone_number" validate:"required"`
-	WechatId      string    `json:"wechat_id" validate:"required"`
+	FirstName     string    `json:"first_name" validate:"required,max=64"`
+	LastName      string    `json:"last_name" validate:"required,max=64"`
+	Gender        string    `json:"gender" validate:"required,gender"`
+	DateOfBirth   time.Time `json:"date_of_birth" validate:"required,past"`
+	PhoneNumber   string    `json:"phone_number" validate:"required,phone"`
+	WechatId      string    `json:"wechat_id" validate:"required,max=64"`
 	HeightCm      int       `json:"height_cm" validate:"required,min=50,max=300"`
 	WeightKg      int       `json:"weight_kg" validate:"required,min=20,max=500"`
-	MaritalStatus string    `json:"marital_status" validate:"required"`
-	NoChildren    int       `json:"no_children"`
-	Languages     string    `json:"languages"`
-	Occupation    string    `json:"occupation"`
-	Religion      string    `json:"religion"`
+	MaritalStatus string    `json:"marital_status" validate:"required,marital_status"`
+	NoChildren    int       `json:"no_children" validate:"min=0,max=30"`
+	Languages     string    `json:"languages" validate:"max=128"`
+	Occupation    string    `json:"occupation" validate:"max=128"`
+	Religion      string    `json:"religion" validate:"max=64"`
 	CdCountry     int       `json:"cd_country" validate:"required"`
 	CdState       int       `json:"cd_state" validate:"required"`
-	CdCity        int       `json:"cd_city"`
-	CdDistrict    int       `json:"cd_district"`
-	StreetAddress string    `json:"street_address" validate:"required"`
-	PostalCode    string    `json:"postal_code" validate:"required"`
+	CdCity        int       `json:"cd_city" validate:"min=0"`
+	CdDistrict    int       `json:"cd_district" validate:"min=0"`
+	StreetAddress string    `json:"street_address" validate:"required,max=255"`
+	PostalCode    string    `json:"postal_code" validate:"required,max=32"`
 }
 
 // Register - Initial registration with email/password
 func Register(c *fiber.Ctx) error {
 	var req RegisterRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	// Staff and doctors may only join through an invitation for their email
+	var invitation *models.Invitation
+	if req.InviteToken != "" {
+		inv, err := findInvitation(database.DB, req.InviteToken, req.Email)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid or expired invitation",
+			})
+		}
+		invitation = inv
+		req.UserType = inv.TyUser
+	} else if !models.CanSelfRegister(req.UserType) {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "This account type requires an invitation",
 		})
 	}
 
@@ -67,6 +87,17 @@ func Register(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := utils.ValidatePassword(req.Password, req.Email); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	acceptedDocs, errResp := signupConsents(c, req.AcceptedDocuments)
+	if errResp != nil {
+		return errResp()
+	}
+
 	// Hash password
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
@@ -75,28 +106,47 @@ func Register(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate OTP
-	otpCode := utils.GenerateOTP()
-
 	// Create user with pending status
 	user := models.User{
-		Email:        req.Email,
-		Password:     hashedPassword,
-		TyUser:       req.UserType,
-		UserStatus:   "Registered", // Will be updated to Active after OTP verification
-		OtpCode:      otpCode,
-		OtpCreatedAt: time.Now(),
+		Email:      req.Email,
+		Password:   hashedPassword,
+		TyUser:     req.UserType,
+		UserStatus: models.UserStatusRegistered, // Moves to EmailVerified after OTP verification
+	}
+
+	if invitation != nil {
+		user.SubtypeUser = invitation.SubtypeUser
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := recordConsents(tx, c, user.CdUser, acceptedDocs, true); err != nil {
+			return err
+		}
+		if invitation != nil {
+			return acceptInvitation(tx, invitation, user.CdUser)
+		}
+		return nil
+	})
+	if err == errInvalidInvitation {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid or expired invitation",
+		})
+	}
+	if err != nil {
 		log.Printf("Error creating user: %v", err)
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to create user",
 		})
 	}
 
-	// Send OTP email
-	if err := utils.SendOTPEmail(req.Email, otpCode); err != nil {
+	// Issue and send OTP; the user can request a new one if this fails
+	otpCode, err := issueOTP(&user, models.OTPPurposeRegistration)
+	if err != nil {
+		log.Printf("Error issuing OTP: %v", err)
+	} else if err := notify.SendOTP(c.UserContext(), &user, models.OTPPurposeRegistration, otpCode); err != nil {
 		log.Printf("Error sending OTP email: %v", err)
 		// Don't fail registration if email fails
 	}
@@ -110,37 +160,32 @@ func Register(c *fiber.Ctx) error {
 // VerifyOTP - Verify email with OTP
 func VerifyOTP(c *fiber.Ctx) error {
 	var req VerifyOTPRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
 	}
 
 	var user models.User
 	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		recordLogin(c, models.LoginEventOTP, models.LoginOutcomeFailure, "unknown_account", nil, req.Email)
 		return c.Status(404).JSON(fiber.Map{
 			"error": "User not found",
 		})
 	}
 
-	// Check OTP validity (10 minutes)
-	if time.Since(user.OtpCreatedAt) > 10*time.Minute {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "OTP has expired. Please request a new one.",
+	if user.UserStatus != models.UserStatusRegistered {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Email already verified",
 		})
 	}
 
-	if user.OtpCode != req.OTP {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid OTP code",
-		})
+	if err := verifyOTP(&user, models.OTPPurposeRegistration, req.OTP); err != nil {
+		recordLogin(c, models.LoginEventOTP, models.LoginOutcomeFailure, loginFailureReason(err), &user, "")
+		return otpErrorResponse(c, err, "Failed to verify OTP")
 	}
+	recordLogin(c, models.LoginEventOTP, models.LoginOutcomeSuccess, "", &user, "")
 
-	// Clear OTP and mark as verified
-	user.OtpCode = ""
-	user.OtpCreatedAt = time.Time{}
-	
-	if err := database.DB.Save(&user).Error; err != nil {
+	if err := user.TransitionStatus(database.DB, models.UserStatusEmailVerified, nil, "email verified by OTP"); err != nil {
+		log.Printf("Error marking email verified: %v", err)
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to verify OTP",
 		})
@@ -155,10 +200,8 @@ func VerifyOTP(c *fiber.Ctx) error {
 // CompleteProfile - Complete user profile after OTP verification
 func CompleteProfile(c *fiber.Ctx) error {
 	var req CompleteProfileRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
 	}
 
 	var user models.User
@@ -168,16 +211,23 @@ func CompleteProfile(c *fiber.Ctx) error {
 		})
 	}
 
+	// Only a freshly verified account may complete its profile here
+	if user.UserStatus != models.UserStatusEmailVerified {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Email must be verified before completing your profile",
+		})
+	}
+
 	// Update user profile
 	user.FirstName = req.FirstName
 	user.LastName = req.LastName
-	user.Gender = req.Gender
+	user.Gender = models.MatchProfileValue(models.Genders, req.Gender)
 	user.DateOfBirth = req.DateOfBirth
-	user.PhoneNumber = req.PhoneNumber
+	user.PhoneNumber = normalizePhone(req.PhoneNumber)
 	user.WechatId = req.WechatId
 	user.HeightCm = req.HeightCm
 	user.WeightKg = req.WeightKg
-	user.MaritalStatus = req.MaritalStatus
+	user.MaritalStatus = models.MatchProfileValue(models.MaritalStatuses, req.MaritalStatus)
 	user.NoChildren = req.NoChildren
 	user.Languages = req.Languages
 	user.Occupation = req.Occupation
@@ -188,48 +238,38 @@ func CompleteProfile(c *fiber.Ctx) error {
 	user.CdDistrict = req.CdDistrict
 	user.StreetAddress = req.StreetAddress
 	user.PostalCode = req.PostalCode
-	user.UserStatus = "Active" // Now fully registered
 
-	if err := database.DB.Save(&user).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("user_status").Save(&user).Error; err != nil {
+			return err
+		}
+		// Now fully registered
+		return user.TransitionStatus(tx, models.UserStatusActive, nil, "profile completed")
+	})
+	if err != nil {
 		log.Printf("Error updating user profile: %v", err)
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to update profile",
 		})
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(&user)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to generate token",
-		})
-	}
-
-	return c.JSON(fiber.Map{
-		"message": "Profile completed successfully",
-		"token":   token,
-		"user": fiber.Map{
-			"id":        user.CdUser,
-			"email":     user.Email,
-			"name":      user.GetFullName(),
-			"userType":  user.TyUser,
-			"status":    user.UserStatus,
-		},
-	})
+	// Privileged accounts continue with their second factor
+	return finishLogin(c, &user, "Profile completed successfully")
 }
 
 // Login - User login
 func Login(c *fiber.Ctx) error {
 	var req LoginRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
 	}
 
 	var user models.User
 	if err := database.DB.Preload("UserType").Where("email = ?", req.Email).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			// Take as long as a wrong password so timing does not reveal the account
+			utils.CheckDummyPassword(req.Password)
+			recordLogin(c, models.LoginEventPassword, models.LoginOutcomeFailure, "unknown_account", nil, req.Email)
 			return c.Status(401).JSON(fiber.Map{
 				"error": "Invalid email or password",
 			})
@@ -241,38 +281,102 @@ func Login(c *fiber.Ctx) error {
 
 	// Check password
 	if !utils.CheckPassword(req.Password, user.Password) {
+		recordLogin(c, models.LoginEventPassword, models.LoginOutcomeFailure, "invalid_password", &user, "")
 		return c.Status(401).JSON(fiber.Map{
 			"error": "Invalid email or password",
 		})
 	}
 
-	// Check if user is active
-	if user.UserStatus != "Active" {
-		return c.Status(403).JSON(fiber.Map{
-			"error": "Account not activated. Please complete your registration.",
+	// Only active accounts may log in
+	if errResp := inactiveAccountResponse(c, &user); errResp != nil {
+		recordLogin(c, models.LoginEventPassword, models.LoginOutcomeFailure, inactiveReason(&user), &user, "")
+		return errResp()
+	}
+
+	recordLogin(c, models.LoginEventPassword, models.LoginOutcomeSuccess, "", &user, "")
+	upgradePasswordHash(&user, req.Password)
+
+	// Privileged accounts continue with their second factor
+	return finishLogin(c, &user, "Login successful")
+}
+
+// upgradePasswordHash rehashes a just-verified password whose stored hash
+// uses an outdated algorithm or cost. Failure only delays the upgrade.
+func upgradePasswordHash(user *models.User, password string) {
+	if !utils.NeedsRehash(user.Password) {
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("Error rehashing password for user %d: %v", user.CdUser, err)
+		return
+	}
+	// Guard on the old hash so a concurrent password reset is not overwritten
+	if err := database.DB.Model(&models.User{}).
+		Where("cd_user = ? AND password = ?", user.CdUser, user.Password).
+		Update("password", hashedPassword).Error; err != nil {
+		log.Printf("Error rehashing password for user %d: %v", user.CdUser, err)
+		return
+	}
+	user.Password = hashedPassword
+}
+
+// inactiveAccountResponse explains why a non-active account cannot log in.
+// It returns nil for active accounts.
+func inactiveAccountResponse(c *fiber.Ctx, user *models.User) func() error {
+	var message string
+	status := 403
+	switch user.UserStatus {
+	case models.UserStatusActive:
+		return nil
+	case models.UserStatusRegistered:
+		message = "Email not verified. Please verify your email."
+	case models.UserStatusEmailVerified:
+		message = "Account not activated. Please complete your registration."
+	case models.UserStatusSuspended:
+		message = "Account suspended. Please contact support."
+	case models.UserStatusDeactivated:
+		message = "Account deactivated. Please contact support."
+	default:
+		status, message = 401, "Invalid email or password"
+	}
+
+	return func() error {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
 		})
 	}
+}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(&user)
+// respondWithSession starts a session and returns its tokens with the user summary
+func respondWithSession(c *fiber.Ctx, user *models.User, message string) error {
+	tokens, err := startSession(c, user)
 	if err != nil {
+		log.Printf("Error starting session: %v", err)
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to generate token",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Login successful",
-		"token":   token,
+	return c.JSON(sessionResponse(user, tokens, message))
+}
+
+func sessionResponse(user *models.User, tokens *tokenPair, message string) fiber.Map {
+	return fiber.Map{
+		"message":       message,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 		"user": fiber.Map{
-			"id":        user.CdUser,
-			"email":     user.Email,
-			"name":      user.GetFullName(),
-			"userType":  user.TyUser,
-			"status":    user.UserStatus,
+			"id":              user.CdUser,
+			"email":           user.Email,
+			"name":            user.GetFullName(),
+			"userType":        user.TyUser,
+			"status":          user.UserStatus,
 			"profileComplete": user.IsProfileComplete(),
 		},
-	})
+	}
 }
 
 // ResendOTP - Resend OTP code
@@ -281,10 +385,8 @@ func ResendOTP(c *fiber.Ctx) error {
 		Email string `json:"email" validate:"required,email"`
 	}
 	
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
 	}
 
 	var user models.User
@@ -294,19 +396,14 @@ func ResendOTP(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate new OTP
-	otpCode := utils.GenerateOTP()
-	user.OtpCode = otpCode
-	user.OtpCreatedAt = time.Now()
-
-	if err := database.DB.Save(&user).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to generate new OTP",
-		})
+	// Generate new OTP, subject to the resend cooldown
+	otpCode, err := issueOTP(&user, models.OTPPurposeRegistration)
+	if err != nil {
+		return otpErrorResponse(c, err, "Failed to generate new OTP")
 	}
 
 	// Send OTP email
-	if err := utils.SendOTPEmail(req.Email, otpCode); err != nil {
+	if err := notify.SendOTP(c.UserContext(), &user, models.OTPPurposeRegistration, otpCode); err != nil {
 		log.Printf("Error sending OTP email: %v", err)
 	}
 
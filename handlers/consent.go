@@ -0,0 +1,504 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errConsentNotCurrent = errors.New("document is not the current version")
+
+type AcceptConsentsRequest struct {
+	DocumentIDs []uint `json:"document_ids" validate:"required,min=1,unique"`
+}
+
+type CreateConsentDocumentRequest struct {
+	Kind      string `json:"kind" validate:"required"`
+	CdCountry int    `json:"cd_country"`
+	Language  string `json:"language" validate:"required"`
+	// Version defaults to one above the highest for the kind and country;
+	// translations of an existing version must name it
+	Version     int        `json:"version"`
+	Title       string     `json:"title" validate:"required"`
+	Content     string     `json:"content"`
+	URL         string     `json:"url"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+// consentStatus is the user's standing on the current document of one kind
+type consentStatus struct {
+	Kind      string                 `json:"kind"`
+	Required  bool                   `json:"required"`
+	Document  models.ConsentDocument `json:"document"`
+	Granted   bool                   `json:"granted"`
+	UpToDate  bool                   `json:"up_to_date"`
+	Version   int                    `json:"accepted_version,omitempty"`
+	DecidedAt *time.Time             `json:"decided_at,omitempty"`
+}
+
+// requestLanguage is the language asked for in ?lang=, else the browser's
+func requestLanguage(c *fiber.Ctx) string {
+	return utils.PrimaryLanguage(c.Query("lang"), c.Get(fiber.HeaderAcceptLanguage))
+}
+
+// consentStatuses pairs each current document for the user's country with
+// their latest decision on it
+func consentStatuses(db *gorm.DB, user *models.User, lang string) ([]consentStatus, error) {
+	current, err := models.CurrentConsentDocuments(db, user.CdCountry, lang)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := models.LatestUserConsents(db, user.CdUser)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]consentStatus, 0, len(current))
+	for i := range current {
+		doc := &current[i]
+		status := consentStatus{
+			Kind:     doc.Kind,
+			Required: doc.Required(),
+			Document: *doc,
+		}
+		if decision, ok := latest[doc.Kind]; ok {
+			createdAt := decision.CreatedAt
+			status.Granted = decision.Granted
+			status.UpToDate = decision.Covers(doc)
+			status.Version = decision.Version
+			status.DecidedAt = &createdAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// resolveConsentDocuments loads the documents being accepted, each of which
+// must be a translation of the version currently in force
+func resolveConsentDocuments(db *gorm.DB, ids []uint, current []models.ConsentDocument) ([]models.ConsentDocument, error) {
+	var docs []models.ConsentDocument
+	if err := db.Where("id IN ?", ids).Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	if len(docs) != len(ids) {
+		return nil, errConsentNotCurrent
+	}
+
+	for i := range docs {
+		found := false
+		for j := range current {
+			if docs[i].IsSameVersion(&current[j]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errConsentNotCurrent
+		}
+	}
+	return docs, nil
+}
+
+// missingRequiredConsents returns the required current documents that none of
+// the accepted documents covers
+func missingRequiredConsents(current, accepted []models.ConsentDocument) []models.ConsentDocument {
+	missing := []models.ConsentDocument{}
+	for i := range current {
+		if !current[i].Required() {
+			continue
+		}
+		covered := false
+		for j := range accepted {
+			if accepted[j].IsSameVersion(&current[i]) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			missing = append(missing, current[i])
+		}
+	}
+	return missing
+}
+
+// signupConsents checks that a new account accepts every required document
+// in force. The country is not known yet, so the default documents apply.
+func signupConsents(c *fiber.Ctx, ids []uint) ([]models.ConsentDocument, func() error) {
+	current, err := models.CurrentConsentDocuments(database.DB, 0, "")
+	if err != nil {
+		log.Printf("Error loading consent documents: %v", err)
+		return nil, func() error {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to load documents",
+			})
+		}
+	}
+
+	var accepted []models.ConsentDocument
+	if len(ids) > 0 {
+		accepted, err = resolveConsentDocuments(database.DB, ids, current)
+		if err == errConsentNotCurrent {
+			return nil, func() error {
+				return c.Status(409).JSON(fiber.Map{
+					"error": "Some documents are no longer current, please review the latest versions",
+				})
+			}
+		}
+		if err != nil {
+			log.Printf("Error loading consent documents: %v", err)
+			return nil, func() error {
+				return c.Status(500).JSON(fiber.Map{
+					"error": "Failed to load documents",
+				})
+			}
+		}
+	}
+
+	if missing := missingRequiredConsents(current, accepted); len(missing) > 0 {
+		return nil, func() error {
+			return c.Status(400).JSON(fiber.Map{
+				"error":     "You must accept the terms to register",
+				"documents": missing,
+			})
+		}
+	}
+	return accepted, nil
+}
+
+// recordConsents appends a decision on each document, with where it was made
+func recordConsents(tx *gorm.DB, c *fiber.Ctx, userID uint, docs []models.ConsentDocument, granted bool) error {
+	for _, doc := range docs {
+		if err := tx.Create(&models.UserConsent{
+			CdUser:     userID,
+			DocumentID: doc.ID,
+			Kind:       doc.Kind,
+			CdCountry:  doc.CdCountry,
+			Version:    doc.Version,
+			Granted:    granted,
+			IPAddress:  c.IP(),
+			UserAgent:  truncateString(c.Get(fiber.HeaderUserAgent), 512),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadConsentUser(c *fiber.Ctx) (*models.User, error) {
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", c.Locals("userID").(uint)).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListConsentDocuments - Current documents for a country, for the registration page
+func ListConsentDocuments(c *fiber.Ctx) error {
+	country := c.QueryInt("country", 0)
+	if country < 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid country",
+		})
+	}
+
+	docs, err := models.CurrentConsentDocuments(database.DB, country, requestLanguage(c))
+	if err != nil {
+		log.Printf("Error loading consent documents: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load documents",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"documents": docs,
+	})
+}
+
+// GetMyConsents - Current documents with the user's decision on each
+func GetMyConsents(c *fiber.Ctx) error {
+	user, err := loadConsentUser(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	statuses, err := consentStatuses(database.DB, user, requestLanguage(c))
+	if err != nil {
+		log.Printf("Error loading consents: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load consents",
+		})
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if status.Required && !status.UpToDate {
+			pending++
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"consents":         statuses,
+		"consent_required": pending > 0,
+	})
+}
+
+// AcceptConsents - Accept the current version of one or more documents
+func AcceptConsents(c *fiber.Ctx) error {
+	var req AcceptConsentsRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	user, err := loadConsentUser(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	lang := requestLanguage(c)
+	current, err := models.CurrentConsentDocuments(database.DB, user.CdCountry, lang)
+	if err != nil {
+		log.Printf("Error loading consent documents: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load documents",
+		})
+	}
+
+	docs, err := resolveConsentDocuments(database.DB, req.DocumentIDs, current)
+	if err == errConsentNotCurrent {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Some documents are no longer current, please review the latest versions",
+		})
+	}
+	if err != nil {
+		log.Printf("Error loading consent documents: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load documents",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return recordConsents(tx, c, user.CdUser, docs, true)
+	})
+	if err != nil {
+		log.Printf("Error recording consents: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to record consents",
+		})
+	}
+
+	pending, err := models.PendingConsents(database.DB, user, lang)
+	if err != nil {
+		log.Printf("Error checking consents: %v", err)
+		pending = nil
+	}
+
+	log.Printf("✅ User %d accepted %d consent documents", user.CdUser, len(docs))
+	return c.JSON(fiber.Map{
+		"message":          "Consent recorded",
+		"consent_required": len(pending) > 0,
+		"pending":          pending,
+	})
+}
+
+// WithdrawConsent - Withdraw an optional consent such as marketing or research use
+func WithdrawConsent(c *fiber.Ctx) error {
+	kind := c.Params("kind")
+	if !models.IsValidConsentKind(kind) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Unknown consent",
+		})
+	}
+	if models.IsRequiredConsent(kind) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "This consent is required to use the platform. You can request erasure of your account instead.",
+		})
+	}
+
+	userID := c.Locals("userID").(uint)
+	latest, err := models.LatestUserConsents(database.DB, userID)
+	if err != nil {
+		log.Printf("Error loading consents: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load consents",
+		})
+	}
+	decision, ok := latest[kind]
+	if !ok || !decision.Granted {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Consent has not been given",
+		})
+	}
+
+	var doc models.ConsentDocument
+	if err := database.DB.Where("id = ?", decision.DocumentID).First(&doc).Error; err != nil {
+		log.Printf("Error loading consent document %d: %v", decision.DocumentID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to withdraw consent",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return recordConsents(tx, c, userID, []models.ConsentDocument{doc}, false)
+	})
+	if err != nil {
+		log.Printf("Error withdrawing consent: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to withdraw consent",
+		})
+	}
+
+	log.Printf("🔒 User %d withdrew %s consent", userID, kind)
+	return c.JSON(fiber.Map{
+		"message": "Consent withdrawn",
+	})
+}
+
+func listConsentHistory(c *fiber.Ctx, userID uint) error {
+	var history []models.UserConsent
+	if err := database.DB.Where("cd_user = ?", userID).Order("id DESC").Find(&history).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load consent history",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"history": history,
+	})
+}
+
+// GetConsentHistory - Every acceptance and withdrawal by the current user
+func GetConsentHistory(c *fiber.Ctx) error {
+	return listConsentHistory(c, c.Locals("userID").(uint))
+}
+
+// GetUserConsents - A user's consent history (admin)
+func GetUserConsents(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	return listConsentHistory(c, uint(userID))
+}
+
+// CreateConsentDocument - Publish a document version or a translation of one (admin)
+func CreateConsentDocument(c *fiber.Ctx) error {
+	var req CreateConsentDocumentRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	req.Language = utils.PrimaryLanguage(req.Language)
+	req.Title = strings.TrimSpace(req.Title)
+	switch {
+	case !models.IsValidConsentKind(req.Kind):
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("Kind must be one of %s", strings.Join(models.ConsentKinds, ", ")),
+		})
+	case req.CdCountry < 0 || req.Version < 0:
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid country or version",
+		})
+	case req.Language == "" || len(req.Language) > 8:
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Language is required",
+		})
+	case req.Title == "" || (req.Content == "" && req.URL == ""):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Title and either content or url are required",
+		})
+	}
+
+	doc := models.ConsentDocument{
+		Kind:        req.Kind,
+		CdCountry:   req.CdCountry,
+		Language:    req.Language,
+		Version:     req.Version,
+		Title:       truncateString(req.Title, 255),
+		Content:     req.Content,
+		URL:         req.URL,
+		PublishedAt: time.Now(),
+	}
+	if req.PublishedAt != nil {
+		doc.PublishedAt = *req.PublishedAt
+	}
+	createdBy := c.Locals("userID").(uint)
+	doc.CreatedBy = &createdBy
+
+	var conflict bool
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if doc.Version == 0 {
+			if err := tx.Model(&models.ConsentDocument{}).
+				Where("kind = ? AND cd_country = ?", doc.Kind, doc.CdCountry).
+				Select("COALESCE(MAX(version), 0) + 1").
+				Scan(&doc.Version).Error; err != nil {
+				return err
+			}
+		}
+
+		var count int64
+		if err := tx.Model(&models.ConsentDocument{}).
+			Where("kind = ? AND cd_country = ? AND language = ? AND version = ?", doc.Kind, doc.CdCountry, doc.Language, doc.Version).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			conflict = true
+			return nil
+		}
+		return tx.Create(&doc).Error
+	})
+	if err != nil {
+		log.Printf("Error creating consent document: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create document",
+		})
+	}
+	if conflict {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "This version already exists in this language",
+		})
+	}
+
+	log.Printf("✅ Consent document %s v%d (%s, country %d) published by user %d", doc.Kind, doc.Version, doc.Language, doc.CdCountry, createdBy)
+	return c.Status(201).JSON(fiber.Map{
+		"message":  "Document published",
+		"document": doc,
+	})
+}
+
+// ListAllConsentDocuments - Every document version, including scheduled ones (admin)
+func ListAllConsentDocuments(c *fiber.Ctx) error {
+	query := database.DB.Order("kind, cd_country, version DESC, language")
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if country := c.Query("country"); country != "" {
+		query = query.Where("cd_country = ?", c.QueryInt("country"))
+	}
+
+	var docs []models.ConsentDocument
+	if err := query.Omit("content").Find(&docs).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load documents",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"documents": docs,
+	})
+}
@@ -0,0 +1,497 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/notify"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// contactChangeUndoTTL is how long the previous address can reverse a change
+const contactChangeUndoTTL = 7 * 24 * time.Hour
+
+var (
+	errContactTaken       = errors.New("contact value already in use")
+	errContactChangeStale = errors.New("contact change no longer applies")
+	errContactUndoOpen    = errors.New("previous contact change can still be undone")
+)
+
+type ChangeEmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ChangePhoneRequest struct {
+	PhoneNumber string `json:"phone_number" validate:"required,phone"`
+}
+
+type ConfirmContactChangeRequest struct {
+	OTP string `json:"otp" validate:"required,len=6"`
+}
+
+type UndoContactChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// contactField describes how one kind of contact detail is stored and verified
+type contactField struct {
+	kind    string
+	column  string
+	label   string
+	purpose models.OTPPurpose
+	channel string
+}
+
+var (
+	emailField = contactField{models.ContactKindEmail, "email", "email address", models.OTPPurposeEmailChange, models.NotificationChannelEmail}
+	phoneField = contactField{models.ContactKindPhone, "phone_number", "phone number", models.OTPPurposePhoneChange, models.NotificationChannelSMS}
+)
+
+func (f contactField) current(user *models.User) string {
+	if f.kind == models.ContactKindPhone {
+		return user.PhoneNumber
+	}
+	return user.Email
+}
+
+// normalizeEmail returns the bare lower-cased address, or "" if it is not valid
+func normalizeEmail(email string) string {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" {
+		return ""
+	}
+	return strings.ToLower(addr.Address)
+}
+
+// normalizePhone strips separators and returns digits with an optional
+// leading +, or "" if the number is not plausible
+func normalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	var b strings.Builder
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '(' || r == ')' || r == '.':
+		default:
+			return ""
+		}
+	}
+	digits := strings.TrimPrefix(b.String(), "+")
+	if len(digits) < 6 || len(digits) > 20 {
+		return ""
+	}
+	return b.String()
+}
+
+// emailTaken reports whether another account already uses email
+func emailTaken(tx *gorm.DB, email string, userID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.User{}).Unscoped().
+		Where("LOWER(email) = ? AND cd_user <> ?", strings.ToLower(email), userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// openContactUndo returns when the undo link of the user's last completed
+// change of kind expires, or nil if no undo is possible any more. Until then
+// no further change of that kind is allowed, so the undo link keeps going to
+// the address that held the account before.
+func openContactUndo(tx *gorm.DB, userID uint, kind string) (*time.Time, error) {
+	var change models.ContactChange
+	err := tx.Where("cd_user = ? AND kind = ? AND status = ? AND undo_expires_at > ?",
+		userID, kind, models.ContactChangeCompleted, time.Now()).
+		Order("undo_expires_at DESC").
+		First(&change).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return change.UndoExpiresAt, nil
+}
+
+// contactUndoOpenResponse refuses a change while the previous one can be undone
+func contactUndoOpenResponse(c *fiber.Ctx, field contactField, until *time.Time) error {
+	body := fiber.Map{
+		"error": "Your " + field.label + " was changed recently. You can change it again once the undo link sent for that change expires.",
+	}
+	if until != nil {
+		body["changeable_at"] = until.UTC()
+	}
+	return c.Status(409).JSON(body)
+}
+
+// requestContactChange sends a code to the new value and records the pending
+// change. The account keeps its current value until the code is confirmed.
+func requestContactChange(c *fiber.Ctx, field contactField, value string) error {
+	userID := c.Locals("userID").(uint)
+
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", userID).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if value == field.current(&user) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "This is already your " + field.label,
+		})
+	}
+	if field.kind == models.ContactKindEmail {
+		taken, err := emailTaken(database.DB, value, userID)
+		if err != nil {
+			log.Printf("Error checking email: %v", err)
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to start " + field.label + " change",
+			})
+		}
+		if taken {
+			return c.Status(409).JSON(fiber.Map{
+				"error": "User with this email already exists",
+			})
+		}
+	}
+	until, err := openContactUndo(database.DB, userID, field.kind)
+	if err != nil {
+		log.Printf("Error checking contact changes: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to start " + field.label + " change",
+		})
+	}
+	if until != nil {
+		return contactUndoOpenResponse(c, field, until)
+	}
+	if !notify.Available(field.channel) {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "Verification by " + field.channel + " is not available",
+		})
+	}
+
+	code, err := issueOTP(&user, field.purpose)
+	if err != nil {
+		return otpErrorResponse(c, err, "Failed to start "+field.label+" change")
+	}
+
+	change := models.ContactChange{
+		CdUser:    userID,
+		Kind:      field.kind,
+		OldValue:  field.current(&user),
+		NewValue:  value,
+		Status:    models.ContactChangePending,
+		ExpiresAt: time.Now().Add(otpTTL),
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the latest request of each kind can be confirmed
+		if err := tx.Model(&models.ContactChange{}).
+			Where("cd_user = ? AND kind = ? AND status = ?", userID, field.kind, models.ContactChangePending).
+			Update("status", models.ContactChangeCancelled).Error; err != nil {
+			return err
+		}
+		return tx.Create(&change).Error
+	})
+	if err != nil {
+		log.Printf("Error recording contact change: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to start " + field.label + " change",
+		})
+	}
+
+	if err := notify.SendContactVerification(c.UserContext(), &user, field.channel, value, code); err != nil {
+		log.Printf("Error sending contact verification code: %v", err)
+		return c.Status(502).JSON(fiber.Map{
+			"error": "Failed to send verification code",
+		})
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"message":    "Verification code sent to the new " + field.label,
+		"expires_at": change.ExpiresAt,
+	})
+}
+
+// confirmContactChange applies a pending change once the code sent to the new
+// value checks out, signs out every other session and tells the old address
+func confirmContactChange(c *fiber.Ctx, field contactField, code string) error {
+	userID := c.Locals("userID").(uint)
+	sessionID := c.Locals("sessionID").(string)
+
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", userID).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	var change models.ContactChange
+	err := database.DB.Where("cd_user = ? AND kind = ? AND status = ?", userID, field.kind, models.ContactChangePending).
+		Order("id DESC").
+		First(&change).Error
+	if err != nil || time.Now().After(change.ExpiresAt) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "No pending " + field.label + " change. Please request a new one.",
+		})
+	}
+
+	if err := verifyOTP(&user, field.purpose, code); err != nil {
+		return otpErrorResponse(c, err, "Failed to confirm "+field.label+" change")
+	}
+
+	undoToken, undoHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to confirm " + field.label + " change",
+		})
+	}
+
+	now := time.Now()
+	undoExpires := now.Add(contactChangeUndoTTL)
+	var undoOpenUntil *time.Time
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Another change may have completed since this one was requested
+		until, err := openContactUndo(tx, userID, field.kind)
+		if err != nil {
+			return err
+		}
+		if until != nil {
+			undoOpenUntil = until
+			return errContactUndoOpen
+		}
+
+		if field.kind == models.ContactKindEmail {
+			taken, err := emailTaken(tx, change.NewValue, userID)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return errContactTaken
+			}
+		}
+
+		// Guard against the value having changed since the request
+		updates := map[string]interface{}{field.column: change.NewValue}
+		if field.kind == models.ContactKindPhone {
+			// The code just sent by SMS proves the number reaches the user
+			updates["phone_verified_at"] = now
+		}
+		result := tx.Model(&models.User{}).
+			Where("cd_user = ? AND "+field.column+" = ?", userID, change.OldValue).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errContactChangeStale
+		}
+
+		result = tx.Model(&models.ContactChange{}).
+			Where("id = ? AND status = ?", change.ID, models.ContactChangePending).
+			Updates(map[string]interface{}{
+				"status":          models.ContactChangeCompleted,
+				"completed_at":    now,
+				"undo_token_hash": undoHash,
+				"undo_expires_at": undoExpires,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errContactChangeStale
+		}
+
+		return revokeSessionsExcept(tx, userID, sessionID)
+	})
+	switch {
+	case errors.Is(err, errContactUndoOpen):
+		return contactUndoOpenResponse(c, field, undoOpenUntil)
+	case errors.Is(err, errContactTaken):
+		return c.Status(409).JSON(fiber.Map{
+			"error": "User with this email already exists",
+		})
+	case errors.Is(err, errContactChangeStale):
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Your " + field.label + " changed in the meantime. Please request a new change.",
+		})
+	case err != nil:
+		log.Printf("Error applying contact change: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to confirm " + field.label + " change",
+		})
+	}
+
+	log.Printf("📧 Changed %s for user %d", field.label, userID)
+
+	// The old address gets the undo link; a phone change is reported to the email on file
+	notifyTo := change.OldValue
+	if field.kind == models.ContactKindPhone {
+		notifyTo = user.Email
+	}
+	if notifyTo != "" {
+		link := strings.TrimRight(appConfig.FrontendURL, "/") + "/account/undo-change?token=" + url.QueryEscape(undoToken)
+		go func(user models.User, to, newValue string) {
+			if err := notify.SendContactChanged(context.Background(), &user, to, field.kind, newValue, link); err != nil {
+				log.Printf("Error sending contact change notice: %v", err)
+			}
+		}(user, notifyTo, change.NewValue)
+	}
+
+	return c.JSON(fiber.Map{
+		"message":    "Your " + field.label + " has been changed. Other sessions have been signed out.",
+		field.column: change.NewValue,
+	})
+}
+
+// RequestEmailChange - Send a code to a new email address before switching to it
+func RequestEmailChange(c *fiber.Ctx) error {
+	var req ChangeEmailRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	email := normalizeEmail(req.Email)
+	if email == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid email address",
+		})
+	}
+
+	return requestContactChange(c, emailField, email)
+}
+
+// ConfirmEmailChange - Switch to the new email address with the code sent to it
+func ConfirmEmailChange(c *fiber.Ctx) error {
+	var req ConfirmContactChangeRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	return confirmContactChange(c, emailField, req.OTP)
+}
+
+// RequestPhoneChange - Send a code to a new phone number before switching to it
+func RequestPhoneChange(c *fiber.Ctx) error {
+	var req ChangePhoneRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	phone := normalizePhone(req.PhoneNumber)
+	if phone == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid phone number",
+		})
+	}
+
+	return requestContactChange(c, phoneField, phone)
+}
+
+// ConfirmPhoneChange - Switch to the new phone number with the code sent to it
+func ConfirmPhoneChange(c *fiber.Ctx) error {
+	var req ConfirmContactChangeRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	return confirmContactChange(c, phoneField, req.OTP)
+}
+
+// UndoContactChange - Restore the previous email or phone number from the link
+// sent to the old address, signing out every session
+func UndoContactChange(c *fiber.Ctx) error {
+	var req UndoContactChangeRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	var change models.ContactChange
+	now := time.Now()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("undo_token_hash = ? AND status = ? AND undo_expires_at > ?",
+				utils.HashToken(req.Token), models.ContactChangeCompleted, now).
+			First(&change).Error; err != nil {
+			return err
+		}
+
+		field := emailField
+		if change.Kind == models.ContactKindPhone {
+			field = phoneField
+		}
+		if field.kind == models.ContactKindEmail {
+			taken, err := emailTaken(tx, change.OldValue, change.CdUser)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return errContactTaken
+			}
+		}
+
+		// Only undo while the account still has the value this change set.
+		// A restored phone number must be confirmed again before it gets codes.
+		updates := map[string]interface{}{field.column: change.OldValue}
+		if field.kind == models.ContactKindPhone {
+			updates["phone_verified_at"] = nil
+		}
+		result := tx.Model(&models.User{}).Unscoped().
+			Where("cd_user = ? AND "+field.column+" = ?", change.CdUser, change.NewValue).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errContactChangeStale
+		}
+
+		if err := tx.Model(&change).Updates(map[string]interface{}{
+			"status":    models.ContactChangeUndone,
+			"undone_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Whoever made the change may have queued another one
+		if err := tx.Model(&models.ContactChange{}).
+			Where("cd_user = ? AND status = ?", change.CdUser, models.ContactChangePending).
+			Update("status", models.ContactChangeCancelled).Error; err != nil {
+			return err
+		}
+
+		return revokeAllCredentials(tx, change.CdUser)
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid or expired link",
+		})
+	case errors.Is(err, errContactTaken), errors.Is(err, errContactChangeStale):
+		return c.Status(409).JSON(fiber.Map{
+			"error": "This change can no longer be undone automatically. Please contact support.",
+		})
+	case err != nil:
+		log.Printf("Error undoing contact change: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to undo change",
+		})
+	}
+
+	log.Printf("🔐 Undid %s change for user %d; all sessions revoked", change.Kind, change.CdUser)
+
+	return c.JSON(fiber.Map{
+		"message": "The change has been undone and all sessions signed out. Please reset your password.",
+	})
+}
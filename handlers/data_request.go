@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/privacy"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// dataExportCooldown spaces out exports; each one holds a full copy of the account
+const dataExportCooldown = time.Hour
+
+var errErasurePending = errors.New("erasure already pending")
+
+type RequestErasureRequest struct {
+	// Confirm must be true; the request is irreversible once carried out
+	Confirm bool   `json:"confirm"`
+	Reason  string `json:"reason"`
+}
+
+type UserErasureRequest struct {
+	Reason string `json:"reason" validate:"required"`
+	// Immediate skips the grace period, e.g. for a verified legal request
+	Immediate bool `json:"immediate"`
+}
+
+func dataRequestResponse(req *models.DataRequest) fiber.Map {
+	return fiber.Map{
+		"id":            req.ID,
+		"kind":          req.Kind,
+		"status":        req.Status,
+		"requested_by":  req.RequestedBy,
+		"reason":        req.Reason,
+		"scheduled_for": req.ScheduledFor,
+		"completed_at":  req.CompletedAt,
+		"archive_size":  req.ArchiveSize,
+		"expires_at":    req.ExpiresAt,
+		"created_at":    req.CreatedAt,
+	}
+}
+
+// listDataRequests omits the archive column, which can be large
+func listDataRequests(c *fiber.Ctx, userID uint) error {
+	var requests []models.DataRequest
+	if err := database.DB.Omit("archive").Where("cd_user = ?", userID).
+		Order("created_at DESC").Find(&requests).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch data requests",
+		})
+	}
+
+	result := make([]fiber.Map, 0, len(requests))
+	for i := range requests {
+		result = append(result, dataRequestResponse(&requests[i]))
+	}
+
+	return c.JSON(fiber.Map{
+		"data_requests": result,
+	})
+}
+
+func createDataExport(c *fiber.Ctx, userID uint, actorID *uint) error {
+	var recent int64
+	if err := database.DB.Model(&models.DataRequest{}).
+		Where("cd_user = ? AND kind = ? AND status = ? AND created_at > ?",
+			userID, models.DataRequestExport, models.DataRequestCompleted, time.Now().Add(-dataExportCooldown)).
+		Count(&recent).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if recent > 0 {
+		return c.Status(429).JSON(fiber.Map{
+			"error": "An export was created less than an hour ago. Download that one instead.",
+		})
+	}
+
+	archive, err := privacy.BuildExport(database.DB, userID)
+	if err != nil {
+		log.Printf("Error exporting data of user %d: %v", userID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to export data",
+		})
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(appConfig.Privacy.ExportTTL)
+	req := models.DataRequest{
+		CdUser:       userID,
+		Kind:         models.DataRequestExport,
+		Status:       models.DataRequestCompleted,
+		RequestedBy:  actorID,
+		ScheduledFor: now,
+		CompletedAt:  &now,
+		Archive:      archive,
+		ArchiveSize:  len(archive),
+		ExpiresAt:    &expiresAt,
+	}
+	if err := database.DB.Create(&req).Error; err != nil {
+		log.Printf("Error saving data export: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to export data",
+		})
+	}
+
+	log.Printf("📦 Exported data of user %d (request %d, %d bytes)", userID, req.ID, len(archive))
+
+	return c.Status(201).JSON(fiber.Map{
+		"message":      "Your data export is ready to download",
+		"data_request": dataRequestResponse(&req),
+	})
+}
+
+func downloadDataExport(c *fiber.Ctx, userID uint, requestID int) error {
+	var req models.DataRequest
+	err := database.DB.Where("id = ? AND cd_user = ? AND kind = ?", requestID, userID, models.DataRequestExport).
+		First(&req).Error
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Export not found",
+		})
+	}
+	if req.Archive == nil || (req.ExpiresAt != nil && time.Now().After(*req.ExpiresAt)) {
+		return c.Status(410).JSON(fiber.Map{
+			"error": "This export has expired. Request a new one.",
+		})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Attachment(fmt.Sprintf("vcm-data-export-%d-%d.zip", userID, req.ID))
+	return c.Send(req.Archive)
+}
+
+// scheduleErasure files an erasure request unless one is already pending
+func scheduleErasure(c *fiber.Ctx, userID uint, actorID *uint, reason string, delay time.Duration) (*models.DataRequest, func() error) {
+	req := models.DataRequest{
+		CdUser:       userID,
+		Kind:         models.DataRequestErasure,
+		Status:       models.DataRequestPending,
+		RequestedBy:  actorID,
+		Reason:       truncateString(reason, 255),
+		ScheduledFor: time.Now().Add(delay),
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var pending int64
+		if err := tx.Model(&models.DataRequest{}).
+			Where("cd_user = ? AND kind = ? AND status = ?", userID, models.DataRequestErasure, models.DataRequestPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return errErasurePending
+		}
+		return tx.Create(&req).Error
+	})
+	if errors.Is(err, errErasurePending) {
+		return nil, func() error {
+			return c.Status(409).JSON(fiber.Map{
+				"error": "An erasure request is already pending",
+			})
+		}
+	}
+	if err != nil {
+		log.Printf("Error scheduling erasure: %v", err)
+		return nil, func() error {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to request erasure",
+			})
+		}
+	}
+	return &req, nil
+}
+
+func cancelErasure(c *fiber.Ctx, userID uint) error {
+	result := database.DB.Model(&models.DataRequest{}).
+		Where("cd_user = ? AND kind = ? AND status = ?", userID, models.DataRequestErasure, models.DataRequestPending).
+		Update("status", models.DataRequestCancelled)
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to cancel erasure",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "No pending erasure request",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Erasure request cancelled",
+	})
+}
+
+// RequestDataExport - Build a downloadable archive of the current user's data
+func RequestDataExport(c *fiber.Ctx) error {
+	return createDataExport(c, c.Locals("userID").(uint), nil)
+}
+
+// ListDataRequests - List the current user's export and erasure requests
+func ListDataRequests(c *fiber.Ctx) error {
+	return listDataRequests(c, c.Locals("userID").(uint))
+}
+
+// DownloadDataExport - Download one of the current user's export archives
+func DownloadDataExport(c *fiber.Ctx) error {
+	requestID, err := c.ParamsInt("id")
+	if err != nil || requestID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request ID",
+		})
+	}
+
+	return downloadDataExport(c, c.Locals("userID").(uint), requestID)
+}
+
+// RequestErasure - Schedule erasure of the current user's personal data after the grace period
+func RequestErasure(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req RequestErasureRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+	if !req.Confirm {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Set confirm to true to erase your account",
+		})
+	}
+
+	erasure, errResp := scheduleErasure(c, userID, nil, req.Reason, appConfig.Privacy.ErasureGracePeriod)
+	if errResp != nil {
+		return errResp()
+	}
+
+	log.Printf("🔒 User %d requested erasure, scheduled for %s", userID, erasure.ScheduledFor.Format(time.RFC3339))
+
+	return c.Status(202).JSON(fiber.Map{
+		"message":      "Your account will be erased on the scheduled date. You can cancel until then.",
+		"data_request": dataRequestResponse(erasure),
+	})
+}
+
+// CancelErasure - Cancel the current user's pending erasure request
+func CancelErasure(c *fiber.Ctx) error {
+	return cancelErasure(c, c.Locals("userID").(uint))
+}
+
+// ExportUserData - Build a downloadable archive of a user's data (admin)
+func ExportUserData(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var count int64
+	if err := database.DB.Unscoped().Model(&models.User{}).Where("cd_user = ?", userID).Count(&count).Error; err != nil || count == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	actorID := c.Locals("userID").(uint)
+	log.Printf("📦 User %d exporting data of user %d", actorID, userID)
+
+	return createDataExport(c, uint(userID), &actorID)
+}
+
+// ListUserDataRequests - List a user's export and erasure requests (admin)
+func ListUserDataRequests(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	return listDataRequests(c, uint(userID))
+}
+
+// DownloadUserDataExport - Download a user's export archive (admin)
+func DownloadUserDataExport(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+	requestID, err := c.ParamsInt("requestId")
+	if err != nil || requestID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request ID",
+		})
+	}
+
+	return downloadDataExport(c, uint(userID), requestID)
+}
+
+// EraseUserData - Schedule or immediately carry out erasure of a user's personal data (admin)
+func EraseUserData(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var req UserErasureRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	actorID := c.Locals("userID").(uint)
+	if uint(userID) == actorID {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "You cannot erase your own account",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", userID).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if errResp := outrankedResponse(c, &user); errResp != nil {
+		return errResp()
+	}
+
+	delay := appConfig.Privacy.ErasureGracePeriod
+	if req.Immediate {
+		delay = 0
+	}
+	erasure, errResp := scheduleErasure(c, user.CdUser, &actorID, req.Reason, delay)
+	if errResp != nil {
+		return errResp()
+	}
+
+	log.Printf("🔒 User %d requested erasure of user %d (request %d)", actorID, userID, erasure.ID)
+
+	if !req.Immediate {
+		return c.Status(202).JSON(fiber.Map{
+			"message":      "Erasure scheduled",
+			"data_request": dataRequestResponse(erasure),
+		})
+	}
+
+	// Run the job now rather than waiting for the next scheduler tick
+	if err := privacy.ProcessDueRequests(database.DB); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	if err := database.DB.Omit("archive").Where("id = ?", erasure.ID).First(erasure).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load erasure request",
+		})
+	}
+	if erasure.Status != models.DataRequestCompleted {
+		return c.Status(500).JSON(fiber.Map{
+			"error":        "Erasure failed",
+			"data_request": dataRequestResponse(erasure),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "Personal data erased",
+		"data_request": dataRequestResponse(erasure),
+	})
+}
+
+// CancelUserErasure - Cancel a user's pending erasure request (admin)
+func CancelUserErasure(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	log.Printf("🔒 User %d cancelling erasure of user %d", c.Locals("userID").(uint), userID)
+
+	return cancelErasure(c, uint(userID))
+}
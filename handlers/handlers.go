@@ -0,0 +1,11 @@
+package handlers
+
+import "vcm-medical-platform/config"
+
+// appConfig holds the settings handlers need at request time
+var appConfig *config.Config
+
+// Init injects the application configuration into the handlers
+func Init(cfg *config.Config) {
+	appConfig = cfg
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+	"vcm-medical-platform/config"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testRPID     = "vcm.test"
+	testOrigin   = "https://app.vcm.test"
+	testPassword = "correct horse battery staple"
+)
+
+// setupTestDB points the handlers at a fresh SQLite database holding the
+// tables the login and passkey flows use, and at a minimal configuration
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&models.UserType{},
+		&models.User{},
+		&models.UserStatusHistory{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.LoginEvent{},
+		&models.UserTOTP{},
+		&models.MFARecoveryCode{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnCeremony{},
+		&models.Permission{},
+		&models.Role{},
+		&models.UserPermissionOverride{},
+		&models.APIKey{},
+		&models.OAuthClient{},
+		&models.OAuthConsent{},
+		&models.OAuthAccessToken{},
+	); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	cfg := &config.Config{
+		Environment: "test",
+		AppSecret:   "test-secret-with-enough-entropy-0123456789",
+		JWT: config.JWTConfig{
+			Expire:        15 * time.Minute,
+			RefreshExpire: 24 * time.Hour,
+			Issuer:        "vcm-test",
+			Audience:      "vcm-test",
+		},
+		MFA: config.MFAConfig{
+			Issuer:            "VCM Test",
+			RequiredUserTypes: []int{models.UserTypeDoctor},
+		},
+		WebAuthn: config.WebAuthnConfig{
+			RPID:    testRPID,
+			RPName:  "VCM Test",
+			Origins: []string{testOrigin},
+		},
+	}
+	Init(cfg)
+	utils.InitJWT(cfg.JWT)
+	utils.InitOTP(cfg.AppSecret)
+	utils.InitSigning(cfg.AppSecret)
+	utils.InitEncryption(cfg.AppSecret)
+	if err := utils.InitWebAuthn(cfg.WebAuthn); err != nil {
+		t.Fatalf("init webauthn: %v", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate signing key: %v", err)
+	}
+	now := time.Now()
+	utils.SetSigningKeys([]utils.SigningKey{{
+		ID:         "test",
+		PrivateKey: key,
+		NotBefore:  now.Add(-time.Hour),
+		ExpiresAt:  now.Add(time.Hour),
+		RetiresAt:  now.Add(2 * time.Hour),
+	}})
+}
+
+// createTestUser stores an active user who logs in with testPassword
+func createTestUser(t *testing.T, email string, userType int) *models.User {
+	t.Helper()
+
+	hash, err := utils.HashPassword(testPassword)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user := models.User{
+		Email:      email,
+		Password:   hash,
+		TyUser:     userType,
+		UserStatus: models.UserStatusActive,
+		FirstName:  "Test",
+		LastName:   "User",
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return &user
+}
+
+// asUser stands in for the JWT middleware on routes that need a logged-in user
+func asUser(user *models.User) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("userID", user.CdUser)
+		c.Locals("userEmail", user.Email)
+		c.Locals("userType", user.TyUser)
+		c.Locals("sessionID", "")
+		return c.Next()
+	}
+}
+
+// postJSON sends body to the app and decodes the JSON response
+func postJSON(t *testing.T, app *fiber.App, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	return sendJSON(t, app, fiber.MethodPost, path, body)
+}
+
+func sendJSON(t *testing.T, app *fiber.App, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("encode request: %v", err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	result := map[string]interface{}{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &result); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, path, raw, err)
+		}
+	}
+	return resp.StatusCode, result
+}
+
+// loginWithPassword runs the password step and returns its response
+func loginWithPassword(t *testing.T, app *fiber.App, email string) map[string]interface{} {
+	t.Helper()
+
+	status, body := postJSON(t, app, "/auth/login", fiber.Map{
+		"email":    email,
+		"password": testPassword,
+	})
+	if status != 200 {
+		t.Fatalf("login: status %d, body %v", status, body)
+	}
+	return body
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/notify"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const invitationTokenPurpose = "invitation"
+
+var errInvalidInvitation = errors.New("invalid or expired invitation")
+
+type CreateInvitationRequest struct {
+	Email       string `json:"email" validate:"required,email"`
+	UserType    int    `json:"userType" validate:"min=0"`
+	SubtypeUser int    `json:"subtype_user"`
+}
+
+// invitationClaims is the signed payload carried in an invitation link
+type invitationClaims struct {
+	ID          uint   `json:"id"`
+	Email       string `json:"email"`
+	UserType    int    `json:"ut"`
+	SubtypeUser int    `json:"st"`
+	ExpiresAt   int64  `json:"exp"`
+	Nonce       string `json:"n"`
+}
+
+// issueInvitationToken signs a fresh token for the invitation, superseding any earlier one
+func issueInvitationToken(tx *gorm.DB, inv *models.Invitation) (string, error) {
+	inv.ExpiresAt = time.Now().Add(appConfig.InviteExpire)
+
+	token, err := utils.SignPayload(invitationTokenPurpose, invitationClaims{
+		ID:          inv.ID,
+		Email:       inv.Email,
+		UserType:    inv.TyUser,
+		SubtypeUser: inv.SubtypeUser,
+		ExpiresAt:   inv.ExpiresAt.Unix(),
+		Nonce:       uuid.NewString(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	inv.TokenHash = utils.HashToken(token)
+	if err := tx.Save(inv).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func sendInvitation(inv *models.Invitation, token string) {
+	var userType models.UserType
+	if err := database.DB.Where("usertype = ?", inv.TyUser).First(&userType).Error; err != nil {
+		log.Printf("Error loading user type %d: %v", inv.TyUser, err)
+	}
+
+	link := strings.TrimRight(appConfig.FrontendURL, "/") + "/register?invite=" + url.QueryEscape(token)
+	if err := notify.SendInvitation(context.Background(), inv.Email, userType.UserTypeName, link); err != nil {
+		log.Printf("Error sending invitation email: %v", err)
+	}
+}
+
+// findInvitation resolves a pending invitation from its token for the given email
+func findInvitation(tx *gorm.DB, token, email string) (*models.Invitation, error) {
+	var claims invitationClaims
+	if err := utils.VerifyPayload(invitationTokenPurpose, token, &claims); err != nil {
+		return nil, errInvalidInvitation
+	}
+	if time.Now().Unix() > claims.ExpiresAt || !strings.EqualFold(claims.Email, email) {
+		return nil, errInvalidInvitation
+	}
+
+	var inv models.Invitation
+	if err := tx.Where("id = ?", claims.ID).First(&inv).Error; err != nil {
+		return nil, errInvalidInvitation
+	}
+	if inv.TokenHash != utils.HashToken(token) || inv.Status() != "pending" {
+		return nil, errInvalidInvitation
+	}
+
+	return &inv, nil
+}
+
+// acceptInvitation marks the invitation as used by the newly created user
+func acceptInvitation(tx *gorm.DB, inv *models.Invitation, userID uint) error {
+	now := time.Now()
+	result := tx.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", inv.ID).
+		Updates(map[string]interface{}{"accepted_at": now, "accepted_by": userID})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidInvitation
+	}
+	return nil
+}
+
+// CreateInvitation - Invite someone to register as a privileged user type (admin)
+func CreateInvitation(c *fiber.Ctx) error {
+	var req CreateInvitationRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	// Admins cannot hand out more privilege than they hold
+	if req.UserType > c.Locals("userType").(int) {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "You cannot invite users above your own user type",
+		})
+	}
+
+	var userType models.UserType
+	if err := database.DB.Where("usertype = ?", req.UserType).First(&userType).Error; err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Unknown user type",
+		})
+	}
+
+	var existingUser models.User
+	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "User with this email already exists",
+		})
+	}
+
+	inv := models.Invitation{
+		Email:       req.Email,
+		TyUser:      req.UserType,
+		SubtypeUser: req.SubtypeUser,
+		InvitedBy:   c.Locals("userID").(uint),
+	}
+
+	var token string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// A new invitation replaces any still-pending one for the same email
+		if err := tx.Model(&models.Invitation{}).
+			Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL", req.Email).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		inv.ExpiresAt = time.Now().Add(appConfig.InviteExpire)
+		if err := tx.Create(&inv).Error; err != nil {
+			return err
+		}
+
+		var err error
+		token, err = issueInvitationToken(tx, &inv)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error creating invitation: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create invitation",
+		})
+	}
+
+	sendInvitation(&inv, token)
+
+	return c.Status(201).JSON(fiber.Map{
+		"message":    "Invitation sent",
+		"invitation": invitationResponse(&inv),
+	})
+}
+
+// ListInvitations - List invitations, optionally filtered by status (admin)
+func ListInvitations(c *fiber.Ctx) error {
+	query := database.DB.Order("created_at DESC")
+
+	now := time.Now()
+	switch c.Query("status") {
+	case "":
+	case "pending":
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", now)
+	case "accepted":
+		query = query.Where("accepted_at IS NOT NULL")
+	case "revoked":
+		query = query.Where("revoked_at IS NOT NULL AND accepted_at IS NULL")
+	case "expired":
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", now)
+	default:
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Unknown invitation status",
+		})
+	}
+
+	var invitations []models.Invitation
+	if err := query.Find(&invitations).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch invitations",
+		})
+	}
+
+	result := make([]fiber.Map, 0, len(invitations))
+	for i := range invitations {
+		result = append(result, invitationResponse(&invitations[i]))
+	}
+
+	return c.JSON(fiber.Map{
+		"invitations": result,
+	})
+}
+
+// ResendInvitation - Issue a fresh link with a new expiry; the old link stops working (admin)
+func ResendInvitation(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid invitation ID",
+		})
+	}
+
+	var inv models.Invitation
+	if err := database.DB.Where("id = ?", id).First(&inv).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Invitation not found",
+		})
+	}
+
+	if status := inv.Status(); status != "pending" && status != "expired" {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Invitation has already been " + status,
+		})
+	}
+
+	token, err := issueInvitationToken(database.DB, &inv)
+	if err != nil {
+		log.Printf("Error reissuing invitation: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to resend invitation",
+		})
+	}
+
+	sendInvitation(&inv, token)
+
+	return c.JSON(fiber.Map{
+		"message":    "Invitation resent",
+		"invitation": invitationResponse(&inv),
+	})
+}
+
+// RevokeInvitation - Cancel a pending invitation (admin)
+func RevokeInvitation(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid invitation ID",
+		})
+	}
+
+	result := database.DB.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to revoke invitation",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Pending invitation not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Invitation revoked",
+	})
+}
+
+func invitationResponse(inv *models.Invitation) fiber.Map {
+	return fiber.Map{
+		"id":           inv.ID,
+		"email":        inv.Email,
+		"userType":     inv.TyUser,
+		"subtype_user": inv.SubtypeUser,
+		"status":       inv.Status(),
+		"invited_by":   inv.InvitedBy,
+		"expires_at":   inv.ExpiresAt,
+		"accepted_at":  inv.AcceptedAt,
+		"created_at":   inv.CreatedAt,
+	}
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// JWKS - Publish the public keys that verify access tokens
+func JWKS(c *fiber.Ctx) error {
+	// Short enough that verifiers see a new key well before it starts signing
+	c.Set(fiber.HeaderCacheControl, "public, max-age=900")
+	return c.JSON(utils.JWKS())
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/notify"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	loginHistoryDefaultLimit = 50
+	loginHistoryMaxLimit     = 200
+)
+
+// requestCountry reads the client's country from the configured proxy header.
+// Unknown ("XX") and malformed values are dropped.
+func requestCountry(c *fiber.Ctx) string {
+	if appConfig.Audit.CountryHeader == "" {
+		return ""
+	}
+	country := strings.ToUpper(strings.TrimSpace(c.Get(appConfig.Audit.CountryHeader)))
+	if len(country) != 2 || country == "XX" {
+		return ""
+	}
+	for _, r := range country {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return ""
+		}
+	}
+	return country
+}
+
+// newLoginEvent describes an authentication attempt made by this request
+func newLoginEvent(c *fiber.Ctx, event, outcome, reason string, userID *uint, email string) models.LoginEvent {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	return models.LoginEvent{
+		CdUser:    userID,
+		Email:     truncateString(strings.ToLower(strings.TrimSpace(email)), 320),
+		Event:     event,
+		Outcome:   outcome,
+		Reason:    reason,
+		IPAddress: c.IP(),
+		UserAgent: truncateString(userAgent, 512),
+		Device:    truncateString(utils.DescribeUserAgent(userAgent), 100),
+		Country:   requestCountry(c),
+	}
+}
+
+// recordLogin stores an authentication attempt. A failure to record is logged
+// but never blocks the login itself.
+func recordLogin(c *fiber.Ctx, event, outcome, reason string, user *models.User, email string) {
+	var userID *uint
+	if user != nil {
+		userID = &user.CdUser
+		if email == "" {
+			email = user.Email
+		}
+	}
+
+	entry := newLoginEvent(c, event, outcome, reason, userID, email)
+	saveLoginEvent(&entry)
+}
+
+func saveLoginEvent(entry *models.LoginEvent) {
+	if err := database.DB.Create(entry).Error; err != nil {
+		log.Printf("Error recording login event: %v", err)
+	}
+}
+
+// recordSessionStart stores a new session and flags it when the device or
+// country has not been seen on a previous session of the user. Flagged
+// sessions trigger an email so the owner can react to a takeover.
+func recordSessionStart(c *fiber.Ctx, user *models.User, session *models.Session) {
+	entry := newLoginEvent(c, models.LoginEventSessionStarted, models.LoginOutcomeSuccess, "", &user.CdUser, user.Email)
+	entry.Device = session.Device
+	entry.SessionID = session.ID
+
+	countSessions := func(condition string, args ...interface{}) (int64, error) {
+		var count int64
+		err := database.DB.Model(&models.LoginEvent{}).
+			Where("cd_user = ? AND event = ?", user.CdUser, models.LoginEventSessionStarted).
+			Where(condition, args...).
+			Count(&count).Error
+		return count, err
+	}
+
+	// The first session is the baseline, not an alert
+	prior, err := countSessions("TRUE")
+	if err == nil && prior > 0 {
+		var sameDevice int64
+		sameDevice, err = countSessions("device = ?", entry.Device)
+		entry.NewDevice = sameDevice == 0
+	}
+	// Only compare countries once one has been recorded, so turning on the
+	// country header does not flag every user's next login
+	if err == nil && prior > 0 && entry.Country != "" {
+		var withCountry, sameCountry int64
+		withCountry, err = countSessions("country <> ''")
+		if err == nil && withCountry > 0 {
+			sameCountry, err = countSessions("country = ?", entry.Country)
+			entry.NewCountry = sameCountry == 0
+		}
+	}
+	if err != nil {
+		log.Printf("Error checking login history for user %d: %v", user.CdUser, err)
+	}
+
+	saveLoginEvent(&entry)
+	if entry.ID == 0 || !entry.IsSuspicious() {
+		return
+	}
+	log.Printf("⚠️  New sign-in for user %d from %s (%s, new device: %t, new country: %t)",
+		user.CdUser, entry.IPAddress, entry.Device, entry.NewDevice, entry.NewCountry)
+
+	if appConfig.Audit.Alerts {
+		go func(user models.User, entry models.LoginEvent) {
+			if err := notify.SendLoginAlert(context.Background(), &user, &entry); err != nil {
+				log.Printf("Error sending login alert: %v", err)
+			}
+		}(*user, entry)
+	}
+}
+
+// inactiveReason is the login history reason for an account that may not log in
+func inactiveReason(user *models.User) string {
+	return "account_" + strings.ToLower(user.UserStatus)
+}
+
+// loginFailureReason names why a code or second-factor check failed
+func loginFailureReason(err error) string {
+	var oe *otpError
+	switch {
+	case errors.Is(err, errInvalidMFAToken):
+		return "invalid_mfa_token"
+	case err == errOTPInvalid:
+		return "invalid_code"
+	case err == errOTPExpired:
+		return "code_expired"
+	case errors.As(err, &oe) && oe.status == 429:
+		return "locked_out"
+	}
+	return "error"
+}
+
+// listLoginEvents applies the shared filters and paging of the history endpoints
+func listLoginEvents(c *fiber.Ctx, query *gorm.DB) error {
+	limit := c.QueryInt("limit", loginHistoryDefaultLimit)
+	if limit < 1 || limit > loginHistoryMaxLimit {
+		limit = loginHistoryDefaultLimit
+	}
+	if before := c.QueryInt("before", 0); before > 0 {
+		query = query.Where("id < ?", before)
+	}
+	if outcome := c.Query("outcome"); outcome != "" {
+		query = query.Where("outcome = ?", outcome)
+	}
+	if c.QueryBool("suspicious") {
+		query = query.Where("new_device OR new_country")
+	}
+
+	var events []models.LoginEvent
+	if err := query.Order("id DESC").Limit(limit).Find(&events).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch login history",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"events": events,
+	})
+}
+
+// GetLoginHistory - The current user's recent logins and failed attempts
+func GetLoginHistory(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	return listLoginEvents(c, database.DB.Where("cd_user = ?", userID))
+}
+
+// GetUserLoginHistory - A user's recent logins and failed attempts (admin)
+func GetUserLoginHistory(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	return listLoginEvents(c, database.DB.Where("cd_user = ?", userID))
+}
+
+// SearchLoginEvents - Login attempts by email or IP, including unknown accounts (admin)
+func SearchLoginEvents(c *fiber.Ctx) error {
+	email := strings.ToLower(strings.TrimSpace(c.Query("email")))
+	ip := strings.TrimSpace(c.Query("ip"))
+	if email == "" && ip == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Filter by email or ip",
+		})
+	}
+
+	query := database.DB.Model(&models.LoginEvent{})
+	if email != "" {
+		query = query.Where("email = ?", email)
+	}
+	if ip != "" {
+		query = query.Where("ip_address = ?", ip)
+	}
+	return listLoginEvents(c, query)
+}
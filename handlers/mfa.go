@@ -0,0 +1,645 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/utils"
+	"vcm-medical-platform/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	mfaTokenPurpose   = "mfa"
+	mfaTokenTTL       = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+var (
+	errInvalidMFAToken    = errors.New("invalid or expired mfa token")
+	errTOTPAlreadyEnabled = errors.New("totp already enabled")
+	errTOTPNotPending     = errors.New("no totp setup in progress")
+	errEnrollmentRefused  = errors.New("login enrolment not allowed")
+)
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" validate:"required,len=6"`
+}
+
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	// Exactly one of Code or RecoveryCode is expected
+	Code         string `json:"code" validate:"omitempty,len=6"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,len=6"`
+}
+
+// mfaClaims is the signed payload proving the password step succeeded
+type mfaClaims struct {
+	UserID    uint   `json:"uid"`
+	ExpiresAt int64  `json:"exp"`
+	Nonce     string `json:"n"`
+}
+
+// finishLogin completes the password step: users with TOTP, or whose user type
+// requires it, get an mfa_token for the second step instead of a session
+func finishLogin(c *fiber.Ctx, user *models.User, message string) error {
+	totp, err := loadTOTP(database.DB, user.CdUser)
+	if err != nil {
+		log.Printf("Error loading TOTP enrolment: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	passkey, err := hasPasskey(database.DB, user.CdUser)
+	if err != nil {
+		log.Printf("Error loading passkeys: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	totpEnabled := totp != nil && totp.ConfirmedAt != nil
+	enrolled := totpEnabled || passkey
+	if !enrolled && !appConfig.MFA.IsRequiredFor(user.TyUser) {
+		return respondWithSession(c, user, message)
+	}
+
+	token, err := utils.SignPayload(mfaTokenPurpose, mfaClaims{
+		UserID:    user.CdUser,
+		ExpiresAt: time.Now().Add(mfaTokenTTL).Unix(),
+		Nonce:     uuid.NewString(),
+	})
+	if err != nil {
+		log.Printf("Error signing MFA token: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	body := fiber.Map{
+		"message":                 "Two-factor authentication required",
+		"mfa_required":            true,
+		"mfa_enrollment_required": !enrolled,
+		"mfa_token":               token,
+		"expires_in":              int(mfaTokenTTL.Seconds()),
+		"mfa_methods":             mfaMethods(totpEnabled, passkey),
+	}
+	if !enrolled {
+		body["message"] = "Two-factor authentication must be set up for this account"
+	}
+	return c.JSON(body)
+}
+
+// mfaMethods lists the second factors the user can complete login with
+func mfaMethods(totp, passkey bool) []string {
+	methods := []string{}
+	if totp {
+		methods = append(methods, "totp")
+	}
+	if passkey {
+		methods = append(methods, "passkey")
+	}
+	return methods
+}
+
+// loadMFAUser resolves the user behind an mfa_token
+func loadMFAUser(token string) (*models.User, error) {
+	var claims mfaClaims
+	if err := utils.VerifyPayload(mfaTokenPurpose, token, &claims); err != nil {
+		return nil, errInvalidMFAToken
+	}
+	if time.Now().Unix() > claims.ExpiresAt {
+		return nil, errInvalidMFAToken
+	}
+
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", claims.UserID).First(&user).Error; err != nil {
+		return nil, errInvalidMFAToken
+	}
+	// The account may have been suspended since the password step
+	if user.UserStatus != models.UserStatusActive {
+		return nil, errInvalidMFAToken
+	}
+	return &user, nil
+}
+
+// checkLoginEnrollment only lets an mfa token enrol a second factor when
+// login reported mfa_enrollment_required: the account must need MFA and
+// have neither a confirmed authenticator app nor a passkey. Otherwise the
+// password alone would be enough to replace the user's second factor.
+func checkLoginEnrollment(user *models.User) error {
+	if !appConfig.MFA.IsRequiredFor(user.TyUser) {
+		return errEnrollmentRefused
+	}
+
+	totp, err := loadTOTP(database.DB, user.CdUser)
+	if err != nil {
+		return err
+	}
+	if totp != nil && totp.ConfirmedAt != nil {
+		return errEnrollmentRefused
+	}
+
+	passkey, err := hasPasskey(database.DB, user.CdUser)
+	if err != nil {
+		return err
+	}
+	if passkey {
+		return errEnrollmentRefused
+	}
+	return nil
+}
+
+// loadTOTP returns the user's enrolment, or nil if there is none
+func loadTOTP(tx *gorm.DB, userID uint) (*models.UserTOTP, error) {
+	var totp models.UserTOTP
+	err := tx.Where("cd_user = ?", userID).First(&totp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &totp, nil
+}
+
+// withMFAThrottle runs check under the user's second-factor throttle, so
+// wrong codes count towards the same exponential lockout as emailed OTPs
+func withMFAThrottle(userID uint, check func(tx *gorm.DB) (bool, error)) error {
+	var result error
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		throttle, err := lockThrottle(tx, userID, models.OTPPurposeMFA)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+			result = otpLockedError(throttle.LockedUntil.Sub(now))
+			return nil
+		}
+
+		ok, err := check(tx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			resetOTPThrottle(throttle)
+		} else {
+			result = recordOTPFailure(throttle, now)
+		}
+		return tx.Save(throttle).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return result
+}
+
+// checkTOTP validates a code against a confirmed enrolment. A time step is
+// accepted at most once so an observed code cannot be replayed.
+func checkTOTP(tx *gorm.DB, userID uint, code string) (bool, error) {
+	var totp models.UserTOTP
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("cd_user = ? AND confirmed_at IS NOT NULL", userID).
+		First(&totp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	secret, err := utils.DecryptSecret(totp.SecretEnc)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok || step <= totp.LastUsedStep {
+		return false, nil
+	}
+
+	return true, tx.Model(&totp).Update("last_used_step", step).Error
+}
+
+// useRecoveryCode consumes one unused recovery code
+func useRecoveryCode(tx *gorm.DB, userID uint, code string) (bool, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	result := tx.Model(&models.MFARecoveryCode{}).
+		Where("cd_user = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashOTP(code)).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// replaceRecoveryCodes discards the user's old codes and returns a fresh set
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("cd_user = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.MFARecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		rows = append(rows, models.MFARecoveryCode{CdUser: userID, CodeHash: utils.HashOTP(code)})
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// beginTOTPSetup stores a new unconfirmed secret, replacing any earlier unfinished setup
+func beginTOTPSetup(user *models.User) (string, string, error) {
+	existing, err := loadTOTP(database.DB, user.CdUser)
+	if err != nil {
+		return "", "", err
+	}
+	if existing != nil && existing.ConfirmedAt != nil {
+		return "", "", errTOTPAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	secretEnc, err := utils.EncryptSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	totp := models.UserTOTP{CdUser: user.CdUser, SecretEnc: secretEnc}
+	err = database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cd_user"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret_enc", "last_used_step", "updated_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "user_totp.confirmed_at IS NULL"}}},
+	}).Create(&totp).Error
+	if err != nil {
+		return "", "", err
+	}
+
+	return secret, utils.TOTPProvisioningURI(appConfig.MFA.Issuer, user.Email, secret), nil
+}
+
+// confirmTOTP activates a pending setup once the user proves their app
+// produces valid codes, and returns the first set of recovery codes
+func confirmTOTP(user *models.User, code string) ([]string, error) {
+	var codes []string
+
+	err := withMFAThrottle(user.CdUser, func(tx *gorm.DB) (bool, error) {
+		var totp models.UserTOTP
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("cd_user = ?", user.CdUser).
+			First(&totp).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, errTOTPNotPending
+		}
+		if err != nil {
+			return false, err
+		}
+		if totp.ConfirmedAt != nil {
+			return false, errTOTPAlreadyEnabled
+		}
+
+		secret, err := utils.DecryptSecret(totp.SecretEnc)
+		if err != nil {
+			return false, err
+		}
+		step, ok := utils.ValidateTOTP(secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+
+		now := time.Now()
+		if err := tx.Model(&totp).Updates(map[string]interface{}{
+			"confirmed_at":   now,
+			"last_used_step": step,
+		}).Error; err != nil {
+			return false, err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, user.CdUser)
+		return err == nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔐 TOTP enabled for user %d", user.CdUser)
+	return codes, nil
+}
+
+// mfaErrorResponse renders second-factor failures
+func mfaErrorResponse(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, errInvalidMFAToken):
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Invalid or expired MFA token. Please log in again.",
+		})
+	case errors.Is(err, errTOTPAlreadyEnabled):
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Two-factor authentication is already enabled",
+		})
+	case errors.Is(err, errEnrollmentRefused):
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Two-factor setup is not available for this login. Verify with an existing second factor instead.",
+		})
+	case errors.Is(err, errTOTPNotPending):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Start two-factor setup first",
+		})
+	case err == errOTPInvalid:
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Invalid authentication code",
+		})
+	}
+	return otpErrorResponse(c, err, fallback)
+}
+
+// GetMFAStatus - Show the current user's two-factor settings
+func GetMFAStatus(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	userType := c.Locals("userType").(int)
+
+	totp, err := loadTOTP(database.DB, userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	var remaining int64
+	if err := database.DB.Model(&models.MFARecoveryCode{}).
+		Where("cd_user = ? AND used_at IS NULL", userID).
+		Count(&remaining).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	var passkeys int64
+	if err := database.DB.Model(&models.WebAuthnCredential{}).
+		Where("cd_user = ?", userID).
+		Count(&passkeys).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	enabled := totp != nil && totp.ConfirmedAt != nil
+	var confirmedAt *time.Time
+	if enabled {
+		confirmedAt = totp.ConfirmedAt
+	}
+
+	return c.JSON(fiber.Map{
+		"totp_enabled":             enabled,
+		"totp_enabled_at":          confirmedAt,
+		"required":                 appConfig.MFA.IsRequiredFor(userType),
+		"recovery_codes_remaining": remaining,
+		"passkeys":                 passkeys,
+	})
+}
+
+// SetupTOTP - Generate a new authenticator secret for the current user
+func SetupTOTP(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", c.Locals("userID")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	return respondWithTOTPSetup(c, &user)
+}
+
+// ConfirmTOTP - Enable TOTP for the current user after checking a first code
+func ConfirmTOTP(c *fiber.Ctx) error {
+	var req TOTPCodeRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", c.Locals("userID")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	codes, err := confirmTOTP(&user, req.Code)
+	if err != nil {
+		return mfaErrorResponse(c, err, "Failed to enable two-factor authentication")
+	}
+
+	return c.JSON(fiber.Map{
+		"message":        "Two-factor authentication enabled. Store these recovery codes somewhere safe.",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTP - Turn off TOTP, unless policy requires it for the user type
+func DisableTOTP(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	if appConfig.MFA.IsRequiredFor(c.Locals("userType").(int)) {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Two-factor authentication is required for your account",
+		})
+	}
+
+	var req TOTPCodeRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	err := withMFAThrottle(userID, func(tx *gorm.DB) (bool, error) {
+		ok, err := checkTOTP(tx, userID, req.Code)
+		if !ok || err != nil {
+			return false, err
+		}
+
+		if err := tx.Where("cd_user = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return false, err
+		}
+		return true, tx.Where("cd_user = ?", userID).Delete(&models.UserTOTP{}).Error
+	})
+	if err != nil {
+		return mfaErrorResponse(c, err, "Failed to disable two-factor authentication")
+	}
+
+	log.Printf("🔓 TOTP disabled for user %d", userID)
+
+	return c.JSON(fiber.Map{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes - Replace all recovery codes after checking a TOTP code
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req TOTPCodeRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	var codes []string
+	err := withMFAThrottle(userID, func(tx *gorm.DB) (bool, error) {
+		ok, err := checkTOTP(tx, userID, req.Code)
+		if !ok || err != nil {
+			return false, err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err == nil, err
+	})
+	if err != nil {
+		return mfaErrorResponse(c, err, "Failed to regenerate recovery codes")
+	}
+
+	return c.JSON(fiber.Map{
+		"message":        "New recovery codes generated. Previous codes no longer work.",
+		"recovery_codes": codes,
+	})
+}
+
+// VerifyLoginMFA - Second login step: exchange an mfa_token and TOTP or recovery code for a session
+func VerifyLoginMFA(c *fiber.Ctx) error {
+	var req MFALoginRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+	if (req.Code == "") == (req.RecoveryCode == "") {
+		return validationErrorResponse(c, validation.FieldError{
+			Field:   "code",
+			Rule:    "required_without",
+			Message: "Provide either a code or a recovery_code",
+		})
+	}
+
+	user, err := loadMFAUser(req.MFAToken)
+	if err != nil {
+		recordLogin(c, models.LoginEventMFA, models.LoginOutcomeFailure, loginFailureReason(err), nil, "")
+		return mfaErrorResponse(c, err, "Failed to verify code")
+	}
+
+	usedRecovery := false
+	err = withMFAThrottle(user.CdUser, func(tx *gorm.DB) (bool, error) {
+		if req.Code != "" {
+			return checkTOTP(tx, user.CdUser, req.Code)
+		}
+		usedRecovery = true
+		return useRecoveryCode(tx, user.CdUser, req.RecoveryCode)
+	})
+	if err != nil {
+		recordLogin(c, models.LoginEventMFA, models.LoginOutcomeFailure, loginFailureReason(err), user, "")
+		return mfaErrorResponse(c, err, "Failed to verify code")
+	}
+
+	reason := ""
+	if usedRecovery {
+		log.Printf("⚠️  User %d logged in with a recovery code", user.CdUser)
+		reason = "recovery_code"
+	}
+	recordLogin(c, models.LoginEventMFA, models.LoginOutcomeSuccess, reason, user, "")
+
+	return respondWithSession(c, user, "Login successful")
+}
+
+// SetupLoginTOTP - Enrol TOTP during login when policy requires it
+func SetupLoginTOTP(c *fiber.Ctx) error {
+	var req MFATokenRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	user, err := loadMFAUser(req.MFAToken)
+	if err != nil {
+		return mfaErrorResponse(c, err, "Failed to start two-factor setup")
+	}
+	if err := checkLoginEnrollment(user); err != nil {
+		return mfaErrorResponse(c, err, "Failed to start two-factor setup")
+	}
+
+	return respondWithTOTPSetup(c, user)
+}
+
+// ConfirmLoginTOTP - Finish enrolment during login and start the session
+func ConfirmLoginTOTP(c *fiber.Ctx) error {
+	var req MFAEnrollRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	user, err := loadMFAUser(req.MFAToken)
+	if err != nil {
+		return mfaErrorResponse(c, err, "Failed to enable two-factor authentication")
+	}
+	if err := checkLoginEnrollment(user); err != nil {
+		return mfaErrorResponse(c, err, "Failed to enable two-factor authentication")
+	}
+
+	codes, err := confirmTOTP(user, req.Code)
+	if err != nil {
+		recordLogin(c, models.LoginEventMFA, models.LoginOutcomeFailure, loginFailureReason(err), user, "")
+		return mfaErrorResponse(c, err, "Failed to enable two-factor authentication")
+	}
+	recordLogin(c, models.LoginEventMFA, models.LoginOutcomeSuccess, "totp_enrolled", user, "")
+
+	tokens, err := startSession(c, user)
+	if err != nil {
+		log.Printf("Error starting session: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	body := sessionResponse(user, tokens, "Two-factor authentication enabled. Store these recovery codes somewhere safe.")
+	body["recovery_codes"] = codes
+	return c.JSON(body)
+}
+
+func respondWithTOTPSetup(c *fiber.Ctx, user *models.User) error {
+	secret, uri, err := beginTOTPSetup(user)
+	if err != nil {
+		if errors.Is(err, errTOTPAlreadyEnabled) {
+			return mfaErrorResponse(c, err, "")
+		}
+		log.Printf("Error starting TOTP setup: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to start two-factor setup",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":          "Scan the QR code with your authenticator app, then confirm with a code",
+		"secret":           secret,
+		"provisioning_uri": uri,
+	})
+}
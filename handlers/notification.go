@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"log"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/notify"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type UpdateNotificationChannelRequest struct {
+	// Channel is email, sms, wechat, or empty for automatic selection
+	Channel string `json:"channel"`
+}
+
+// GetNotificationSettings - Show where the current user receives codes
+func GetNotificationSettings(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", c.Locals("userID")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	available := []string{}
+	for _, ch := range []string{models.NotificationChannelEmail, models.NotificationChannelSMS, models.NotificationChannelWeChat} {
+		if notify.Available(ch) && notify.Recipient(&user, ch) != "" {
+			available = append(available, ch)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"channel":           user.NotificationChannel,
+		"effective_channel": notify.ChannelFor(&user),
+		"available":         available,
+	})
+}
+
+// UpdateNotificationChannel - Choose the channel for one-time codes
+func UpdateNotificationChannel(c *fiber.Ctx) error {
+	var req UpdateNotificationChannelRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	if req.Channel != "" && !models.IsValidNotificationChannel(req.Channel) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Unknown notification channel",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", c.Locals("userID")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if req.Channel != "" {
+		if !notify.Available(req.Channel) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "This notification channel is not available",
+			})
+		}
+		if notify.Recipient(&user, req.Channel) == "" {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Verify a phone number or link WeChat before choosing this channel",
+			})
+		}
+	}
+
+	user.NotificationChannel = req.Channel
+	if err := database.DB.Model(&user).Update("notification_channel", req.Channel).Error; err != nil {
+		log.Printf("Error updating notification channel: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update notification channel",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":           "Notification channel updated",
+		"channel":           user.NotificationChannel,
+		"effective_channel": notify.ChannelFor(&user),
+	})
+}
+
+// GetUserNotifications - Recent delivery attempts for a user (admin)
+func GetUserNotifications(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var deliveries []models.NotificationDelivery
+	if err := database.DB.Where("cd_user = ?", userID).
+		Order("created_at DESC").
+		Limit(100).
+		Find(&deliveries).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch notifications",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"notifications": deliveries,
+	})
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"log"
+	"net/url"
+	"strings"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type OAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required"`
+	LogoURL      string   `json:"logo_url"`
+	RedirectURIs []string `json:"redirect_uris" validate:"required,min=1"`
+	Scopes       []string `json:"scopes"`
+	// Public clients, such as single-page portals, get no secret
+	Public bool `json:"public"`
+}
+
+// validRedirectURI accepts absolute https URIs, and plain http only on loopback for development
+func validRedirectURI(uri string) bool {
+	if uri == "" || strings.ContainsAny(uri, " \t\r\n") {
+		return false
+	}
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+	return false
+}
+
+// clientScopes validates the scopes a client may request; none means all, and openid is always allowed
+func clientScopes(requested []string) ([]string, bool) {
+	if len(requested) == 0 {
+		return models.SupportedOAuthScopes, true
+	}
+
+	wanted := map[string]bool{models.OAuthScopeOpenID: true}
+	for _, scope := range requested {
+		if !models.IsValidOAuthScope(scope) {
+			return nil, false
+		}
+		wanted[scope] = true
+	}
+
+	var scopes []string
+	for _, scope := range models.SupportedOAuthScopes {
+		if wanted[scope] {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, true
+}
+
+// applyOAuthClientRequest copies the editable fields of req onto client
+func applyOAuthClientRequest(c *fiber.Ctx, req *OAuthClientRequest, client *models.OAuthClient) func() error {
+	if strings.TrimSpace(req.Name) == "" || len(req.Name) > 128 {
+		return func() error {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Name is required and must be at most 128 characters",
+			})
+		}
+	}
+	// The logo is shown on the consent screen, so it must not downgrade the page to http
+	if req.LogoURL != "" && (len(req.LogoURL) > 512 || !strings.HasPrefix(req.LogoURL, "https://")) {
+		return func() error {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Logo URL must be an https URL of at most 512 characters",
+			})
+		}
+	}
+	if len(req.RedirectURIs) == 0 {
+		return func() error {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "At least one redirect URI is required",
+			})
+		}
+	}
+	for _, uri := range req.RedirectURIs {
+		if !validRedirectURI(uri) {
+			return func() error {
+				return c.Status(400).JSON(fiber.Map{
+					"error": "Redirect URIs must be absolute https URLs without a fragment: " + uri,
+				})
+			}
+		}
+	}
+	scopes, ok := clientScopes(req.Scopes)
+	if !ok {
+		return func() error {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Unknown scope",
+			})
+		}
+	}
+
+	client.Name = strings.TrimSpace(req.Name)
+	client.LogoURL = req.LogoURL
+	client.RedirectURIs = strings.Join(req.RedirectURIs, " ")
+	client.Scopes = strings.Join(scopes, " ")
+	return nil
+}
+
+// CreateOAuthClient - Register a partner portal for single sign-on (admin)
+func CreateOAuthClient(c *fiber.Ctx) error {
+	var req OAuthClientRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	createdBy := c.Locals("userID").(uint)
+	client := models.OAuthClient{
+		ClientID:  uuid.NewString(),
+		CreatedBy: &createdBy,
+	}
+	if errResp := applyOAuthClientRequest(c, &req, &client); errResp != nil {
+		return errResp()
+	}
+
+	var secret string
+	if !req.Public {
+		var err error
+		secret, client.SecretHash, err = utils.GenerateOpaqueToken()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to generate client secret",
+			})
+		}
+	}
+
+	if err := database.DB.Create(&client).Error; err != nil {
+		log.Printf("Error creating OAuth client: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create client",
+		})
+	}
+
+	response := fiber.Map{
+		"message": "Client registered",
+		"client":  oauthClientResponse(&client),
+	}
+	if secret != "" {
+		// Only the hash is stored, so this is the one chance to copy it
+		response["client_secret"] = secret
+	}
+	return c.Status(201).JSON(response)
+}
+
+// ListOAuthClients - List registered partner portals (admin)
+func ListOAuthClients(c *fiber.Ctx) error {
+	var clients []models.OAuthClient
+	if err := database.DB.Order("created_at DESC").Find(&clients).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch clients",
+		})
+	}
+
+	result := make([]fiber.Map, 0, len(clients))
+	for i := range clients {
+		result = append(result, oauthClientResponse(&clients[i]))
+	}
+
+	return c.JSON(fiber.Map{
+		"clients": result,
+	})
+}
+
+// UpdateOAuthClient - Change a client's name, logo, redirect URIs or scopes (admin)
+func UpdateOAuthClient(c *fiber.Ctx) error {
+	var client models.OAuthClient
+	if err := database.DB.Where("client_id = ?", c.Params("clientId")).First(&client).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Client not found",
+		})
+	}
+
+	var req OAuthClientRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+	if errResp := applyOAuthClientRequest(c, &req, &client); errResp != nil {
+		return errResp()
+	}
+
+	if err := database.DB.Save(&client).Error; err != nil {
+		log.Printf("Error updating OAuth client: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update client",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Client updated",
+		"client":  oauthClientResponse(&client),
+	})
+}
+
+// RotateOAuthClientSecret - Replace a confidential client's secret; the old one stops working (admin)
+func RotateOAuthClientSecret(c *fiber.Ctx) error {
+	var client models.OAuthClient
+	if err := database.DB.Where("client_id = ?", c.Params("clientId")).First(&client).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Client not found",
+		})
+	}
+	if !client.IsConfidential() {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Public clients have no secret",
+		})
+	}
+
+	secret, secretHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to generate client secret",
+		})
+	}
+	if err := database.DB.Model(&client).Update("secret_hash", secretHash).Error; err != nil {
+		log.Printf("Error rotating OAuth client secret: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to rotate client secret",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":       "Client secret rotated",
+		"client_secret": secret,
+	})
+}
+
+// DeleteOAuthClient - Remove a client with its consents and tokens (admin)
+func DeleteOAuthClient(c *fiber.Ctx) error {
+	result := database.DB.Where("client_id = ?", c.Params("clientId")).Delete(&models.OAuthClient{})
+	if result.Error != nil {
+		log.Printf("Error deleting OAuth client: %v", result.Error)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete client",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Client not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Client deleted",
+	})
+}
+
+func oauthClientResponse(client *models.OAuthClient) fiber.Map {
+	return fiber.Map{
+		"client_id":     client.ClientID,
+		"name":          client.Name,
+		"logo_url":      client.LogoURL,
+		"redirect_uris": strings.Fields(client.RedirectURIs),
+		"scopes":        strings.Fields(client.Scopes),
+		"public":        !client.IsConfidential(),
+		"created_by":    client.CreatedBy,
+		"created_at":    client.CreatedAt,
+		"updated_at":    client.UpdatedAt,
+	}
+}
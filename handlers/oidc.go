@@ -0,0 +1,675 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// oauthCodeTTL bounds the time between consent and the partner's code exchange
+	oauthCodeTTL   = 5 * time.Minute
+	pkceMethodS256 = "S256"
+)
+
+var (
+	errInvalidGrant    = errors.New("invalid authorization code")
+	errOAuthCodeReused = errors.New("authorization code reused")
+)
+
+// AuthorizationRequest holds the parameters the partner sent to the consent
+// page, which passes them on unchanged
+type AuthorizationRequest struct {
+	ClientID            string `json:"client_id" query:"client_id"`
+	RedirectURI         string `json:"redirect_uri" query:"redirect_uri"`
+	ResponseType        string `json:"response_type" query:"response_type"`
+	Scope               string `json:"scope" query:"scope"`
+	State               string `json:"state" query:"state"`
+	Nonce               string `json:"nonce" query:"nonce"`
+	CodeChallenge       string `json:"code_challenge" query:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" query:"code_challenge_method"`
+	Prompt              string `json:"prompt" query:"prompt"`
+}
+
+type AuthorizeRequest struct {
+	AuthorizationRequest
+	Approve bool `json:"approve"`
+}
+
+// oauthError is an RFC 6749 error code with a description for developers
+type oauthError struct {
+	Code        string
+	Description string
+}
+
+func oidcDisabled(c *fiber.Ctx) error {
+	return c.Status(404).JSON(fiber.Map{
+		"error": "OpenID Connect is not enabled",
+	})
+}
+
+// oauthErrorResponse replies in the error format OAuth client libraries expect
+func oauthErrorResponse(c *fiber.Ctx, status int, code, description string) error {
+	return c.Status(status).JSON(fiber.Map{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// loadAuthorizationClient resolves the client and checks the redirect URI.
+// Until both are known to be good, errors are shown to the user and never
+// sent to the redirect URI.
+func loadAuthorizationClient(c *fiber.Ctx, req *AuthorizationRequest) (*models.OAuthClient, func() error) {
+	var client models.OAuthClient
+	if err := database.DB.Where("client_id = ?", req.ClientID).First(&client).Error; err != nil {
+		return nil, func() error {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Unknown client",
+			})
+		}
+	}
+	if !client.AllowsRedirectURI(req.RedirectURI) {
+		return nil, func() error {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Redirect URI is not registered for this client",
+			})
+		}
+	}
+	return &client, nil
+}
+
+// checkAuthorizationRequest validates the remaining parameters and returns the scopes to grant
+func checkAuthorizationRequest(client *models.OAuthClient, req *AuthorizationRequest) ([]string, *oauthError) {
+	if req.ResponseType != "code" {
+		return nil, &oauthError{"unsupported_response_type", "Only the authorization code flow is supported"}
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != pkceMethodS256 {
+		return nil, &oauthError{"invalid_request", "PKCE with code_challenge_method S256 is required"}
+	}
+	if len(req.CodeChallenge) > 128 || len(req.Nonce) > 255 {
+		return nil, &oauthError{"invalid_request", "code_challenge or nonce is too long"}
+	}
+
+	requested := map[string]bool{}
+	for _, scope := range strings.Fields(req.Scope) {
+		requested[scope] = true
+	}
+	if !requested[models.OAuthScopeOpenID] {
+		return nil, &oauthError{"invalid_scope", "The openid scope is required"}
+	}
+
+	// Scopes the client is not registered for are dropped rather than refused
+	var scopes []string
+	for _, scope := range strings.Fields(client.Scopes) {
+		if requested[scope] {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// authorizationRedirect builds the URL that returns the user to the partner
+func authorizationRedirect(req *AuthorizationRequest, params url.Values) string {
+	u, _ := url.Parse(req.RedirectURI)
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	// RFC 9207: lets the partner tell our response apart from another provider's
+	q.Set("iss", appConfig.OIDC.Issuer)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// authorizationErrorResponse reports an error to the partner through its redirect URI
+func authorizationErrorResponse(c *fiber.Ctx, req *AuthorizationRequest, oerr *oauthError) error {
+	return c.Status(400).JSON(fiber.Map{
+		"error": oerr.Description,
+		"redirect_to": authorizationRedirect(req, url.Values{
+			"error":             {oerr.Code},
+			"error_description": {oerr.Description},
+		}),
+	})
+}
+
+func hasPrompt(prompt, value string) bool {
+	for _, p := range strings.Fields(prompt) {
+		if p == value {
+			return true
+		}
+	}
+	return false
+}
+
+// needsConsent reports whether the user must be asked before sharing scopes with the client
+func needsConsent(userID uint, clientID string, scopes []string, prompt string) (bool, error) {
+	if hasPrompt(prompt, "consent") {
+		return true, nil
+	}
+
+	var consent models.OAuthConsent
+	err := database.DB.Where("cd_user = ? AND client_id = ?", userID, clientID).First(&consent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	granted := map[string]bool{}
+	for _, scope := range strings.Fields(consent.Scopes) {
+		granted[scope] = true
+	}
+	for _, scope := range scopes {
+		if !granted[scope] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mergeScopes returns the union of both scope strings in display order
+func mergeScopes(a, b string) string {
+	present := map[string]bool{}
+	for _, scope := range strings.Fields(a + " " + b) {
+		present[scope] = true
+	}
+
+	var scopes []string
+	for _, scope := range models.SupportedOAuthScopes {
+		if present[scope] {
+			scopes = append(scopes, scope)
+		}
+	}
+	return strings.Join(scopes, " ")
+}
+
+// oidcUserClaims returns the claims the granted scopes release about the user
+func oidcUserClaims(user *models.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": strconv.FormatUint(uint64(user.CdUser), 10),
+		// Partner portals route users by account type, so it comes with openid
+		"user_type":      user.TyUser,
+		"user_type_name": user.UserType.UserTypeName,
+	}
+
+	for _, scope := range scopes {
+		switch scope {
+		case models.OAuthScopeProfile:
+			claims["name"] = strings.TrimSpace(user.FirstName + " " + user.LastName)
+			claims["given_name"] = user.FirstName
+			claims["family_name"] = user.LastName
+			claims["gender"] = strings.ToLower(user.Gender)
+			// 1900-01-01 is the column default, not a real birthday
+			if user.DateOfBirth.Year() > 1900 {
+				claims["birthdate"] = user.DateOfBirth.Format("2006-01-02")
+			}
+			claims["updated_at"] = user.UpdatedAt.Unix()
+		case models.OAuthScopeEmail:
+			claims["email"] = user.Email
+			claims["email_verified"] = user.UserStatus != models.UserStatusRegistered
+		case models.OAuthScopePhone:
+			if user.PhoneNumber != "" {
+				claims["phone_number"] = user.PhoneNumber
+				claims["phone_number_verified"] = user.PhoneVerifiedAt != nil
+			}
+		}
+	}
+	return claims
+}
+
+// OpenIDConfiguration - Publish the provider metadata partners configure single sign-on from
+func OpenIDConfiguration(c *fiber.Ctx) error {
+	if !appConfig.OIDC.IsConfigured() {
+		return oidcDisabled(c)
+	}
+
+	issuer := appConfig.OIDC.Issuer
+	c.Set(fiber.HeaderCacheControl, "public, max-age=3600")
+	return c.JSON(fiber.Map{
+		"issuer":                                issuer,
+		"authorization_endpoint":                appConfig.OIDC.ConsentURL,
+		"token_endpoint":                        issuer + "/api/v1/oauth/token",
+		"userinfo_endpoint":                     issuer + "/api/v1/oauth/userinfo",
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"scopes_supported":                      models.SupportedOAuthScopes,
+		"response_types_supported":              []string{"code"},
+		"response_modes_supported":              []string{"query"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{jwt.SigningMethodEdDSA.Alg()},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{pkceMethodS256},
+		"prompt_values_supported":               []string{"none", "consent"},
+		"claims_supported": []string{
+			"sub", "iss", "aud", "azp", "exp", "iat", "auth_time", "nonce",
+			"user_type", "user_type_name",
+			"name", "given_name", "family_name", "gender", "birthdate", "updated_at",
+			"email", "email_verified", "phone_number", "phone_number_verified",
+		},
+		"authorization_response_iss_parameter_supported": true,
+	})
+}
+
+// GetAuthorizationRequest - Validate an authorization request and describe it for the consent screen
+func GetAuthorizationRequest(c *fiber.Ctx) error {
+	if !appConfig.OIDC.IsConfigured() {
+		return oidcDisabled(c)
+	}
+
+	var req AuthorizationRequest
+	if err := c.QueryParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid authorization request",
+		})
+	}
+
+	client, errResp := loadAuthorizationClient(c, &req)
+	if errResp != nil {
+		return errResp()
+	}
+	scopes, oerr := checkAuthorizationRequest(client, &req)
+	if oerr != nil {
+		return authorizationErrorResponse(c, &req, oerr)
+	}
+
+	consentRequired, err := needsConsent(c.Locals("userID").(uint), client.ClientID, scopes, req.Prompt)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if consentRequired && hasPrompt(req.Prompt, "none") {
+		return authorizationErrorResponse(c, &req, &oauthError{"consent_required", "The user has not approved this client"})
+	}
+
+	scopeList := make([]fiber.Map, 0, len(scopes))
+	for _, scope := range scopes {
+		scopeList = append(scopeList, fiber.Map{
+			"scope":       scope,
+			"description": models.OAuthScopeDescriptions[scope],
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"client": fiber.Map{
+			"client_id": client.ClientID,
+			"name":      client.Name,
+			"logo_url":  client.LogoURL,
+		},
+		"scopes":           scopeList,
+		"consent_required": consentRequired,
+	})
+}
+
+// Authorize - Record the user's decision and return the redirect back to the partner
+func Authorize(c *fiber.Ctx) error {
+	if !appConfig.OIDC.IsConfigured() {
+		return oidcDisabled(c)
+	}
+
+	var req AuthorizeRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	client, errResp := loadAuthorizationClient(c, &req.AuthorizationRequest)
+	if errResp != nil {
+		return errResp()
+	}
+	scopes, oerr := checkAuthorizationRequest(client, &req.AuthorizationRequest)
+	if oerr != nil {
+		return authorizationErrorResponse(c, &req.AuthorizationRequest, oerr)
+	}
+
+	if !req.Approve {
+		return c.JSON(fiber.Map{
+			"redirect_to": authorizationRedirect(&req.AuthorizationRequest, url.Values{
+				"error":             {"access_denied"},
+				"error_description": {"The user declined the request"},
+			}),
+		})
+	}
+
+	userID := c.Locals("userID").(uint)
+
+	// auth_time is when the user logged in on this device, not when they consented
+	var session models.Session
+	if err := database.DB.Where("id = ?", c.Locals("sessionID").(string)).First(&session).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	rawCode, codeHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to generate authorization code",
+		})
+	}
+
+	grantedScopes := strings.Join(scopes, " ")
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var existing models.OAuthConsent
+		if err := tx.Where("cd_user = ? AND client_id = ?", userID, client.ClientID).
+			Find(&existing).Error; err != nil {
+			return err
+		}
+
+		consent := models.OAuthConsent{
+			CdUser:   userID,
+			ClientID: client.ClientID,
+			Scopes:   mergeScopes(existing.Scopes, grantedScopes),
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "cd_user"}, {Name: "client_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at"}),
+		}).Create(&consent).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.OAuthAuthorizationCode{
+			CodeHash:      codeHash,
+			ClientID:      client.ClientID,
+			CdUser:        userID,
+			RedirectURI:   req.RedirectURI,
+			Scopes:        grantedScopes,
+			Nonce:         req.Nonce,
+			CodeChallenge: req.CodeChallenge,
+			AuthTime:      session.CreatedAt,
+			ExpiresAt:     time.Now().Add(oauthCodeTTL),
+		}).Error
+	})
+	if err != nil {
+		log.Printf("Error issuing authorization code: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to authorize client",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"redirect_to": authorizationRedirect(&req.AuthorizationRequest, url.Values{"code": {rawCode}}),
+	})
+}
+
+// parseBasicAuth decodes client credentials sent with HTTP Basic authentication
+func parseBasicAuth(header string) (clientID, secret string, ok bool) {
+	encoded, found := strings.CutPrefix(header, "Basic ")
+	if !found {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	id, pass, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", "", false
+	}
+
+	// RFC 6749 form-encodes both parts before Base64
+	if clientID, err = url.QueryUnescape(id); err != nil {
+		return "", "", false
+	}
+	if secret, err = url.QueryUnescape(pass); err != nil {
+		return "", "", false
+	}
+	return clientID, secret, true
+}
+
+// authenticateOAuthClient identifies the client at the token endpoint.
+// Confidential clients must present their secret; public clients rely on PKCE.
+func authenticateOAuthClient(c *fiber.Ctx) (*models.OAuthClient, bool) {
+	clientID, secret := c.FormValue("client_id"), c.FormValue("client_secret")
+	if id, pass, ok := parseBasicAuth(c.Get(fiber.HeaderAuthorization)); ok {
+		clientID, secret = id, pass
+	}
+	if clientID == "" {
+		return nil, false
+	}
+
+	var client models.OAuthClient
+	if err := database.DB.Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return nil, false
+	}
+	if client.IsConfidential() {
+		if secret == "" || subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(client.SecretHash)) != 1 {
+			return nil, false
+		}
+	}
+	return &client, true
+}
+
+// OAuthToken - Exchange an authorization code for an access token and ID token
+func OAuthToken(c *fiber.Ctx) error {
+	if !appConfig.OIDC.IsConfigured() {
+		return oidcDisabled(c)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+
+	client, ok := authenticateOAuthClient(c)
+	if !ok {
+		if strings.HasPrefix(c.Get(fiber.HeaderAuthorization), "Basic ") {
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+		}
+		return oauthErrorResponse(c, 401, "invalid_client", "Client authentication failed")
+	}
+
+	if c.FormValue("grant_type") != "authorization_code" {
+		return oauthErrorResponse(c, 400, "unsupported_grant_type", "Only authorization_code is supported")
+	}
+
+	now := time.Now()
+	ttl := appConfig.OIDC.TokenTTL
+	var codeID uint
+	var accessToken, idToken, grantedScopes string
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var code models.OAuthAuthorizationCode
+		if err := tx.Where("code_hash = ?", utils.HashToken(c.FormValue("code"))).First(&code).Error; err != nil {
+			return errInvalidGrant
+		}
+		codeID = code.ID
+		if code.ClientID != client.ClientID || now.After(code.ExpiresAt) {
+			return errInvalidGrant
+		}
+
+		result := tx.Model(&models.OAuthAuthorizationCode{}).
+			Where("id = ? AND used_at IS NULL", code.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOAuthCodeReused
+		}
+
+		if code.RedirectURI != c.FormValue("redirect_uri") ||
+			!utils.VerifyCodeChallenge(c.FormValue("code_verifier"), code.CodeChallenge) {
+			return errInvalidGrant
+		}
+
+		var user models.User
+		if err := tx.Preload("UserType").Where("cd_user = ?", code.CdUser).First(&user).Error; err != nil {
+			return errInvalidGrant
+		}
+		if user.UserStatus != models.UserStatusActive {
+			return errInvalidGrant
+		}
+
+		rawToken, tokenHash, err := utils.GenerateOpaqueToken()
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&models.OAuthAccessToken{
+			TokenHash: tokenHash,
+			ClientID:  client.ClientID,
+			CdUser:    user.CdUser,
+			CodeID:    &code.ID,
+			Scopes:    code.Scopes,
+			ExpiresAt: now.Add(ttl),
+		}).Error; err != nil {
+			return err
+		}
+
+		claims := jwt.MapClaims(oidcUserClaims(&user, strings.Fields(code.Scopes)))
+		claims["iss"] = appConfig.OIDC.Issuer
+		claims["aud"] = client.ClientID
+		claims["azp"] = client.ClientID
+		claims["iat"] = now.Unix()
+		claims["exp"] = now.Add(ttl).Unix()
+		claims["auth_time"] = code.AuthTime.Unix()
+		if code.Nonce != "" {
+			claims["nonce"] = code.Nonce
+		}
+
+		idToken, err = utils.SignJWT(claims)
+		if err != nil {
+			return err
+		}
+		accessToken = rawToken
+		grantedScopes = code.Scopes
+		return nil
+	})
+
+	if errors.Is(err, errOAuthCodeReused) {
+		// A code redeemed twice may have been intercepted; revoke what it produced
+		log.Printf("⚠️  Authorization code reuse detected for client %s, revoking its tokens", client.ClientID)
+		if err := database.DB.Model(&models.OAuthAccessToken{}).
+			Where("code_id = ? AND revoked_at IS NULL", codeID).
+			Update("revoked_at", now).Error; err != nil {
+			log.Printf("Error revoking OAuth access tokens: %v", err)
+		}
+		return oauthErrorResponse(c, 400, "invalid_grant", "Invalid or expired authorization code")
+	}
+	if errors.Is(err, errInvalidGrant) {
+		return oauthErrorResponse(c, 400, "invalid_grant", "Invalid or expired authorization code")
+	}
+	if err != nil {
+		log.Printf("Error exchanging authorization code: %v", err)
+		return oauthErrorResponse(c, 500, "server_error", "Failed to issue tokens")
+	}
+
+	return c.JSON(fiber.Map{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(ttl.Seconds()),
+		"id_token":     idToken,
+		"scope":        grantedScopes,
+	})
+}
+
+// UserInfo - Return the claims released to the client that holds the access token
+func UserInfo(c *fiber.Ctx) error {
+	if !appConfig.OIDC.IsConfigured() {
+		return oidcDisabled(c)
+	}
+
+	invalidToken := func() error {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return oauthErrorResponse(c, 401, "invalid_token", "Invalid or expired access token")
+	}
+
+	token, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !found || token == "" {
+		return invalidToken()
+	}
+
+	var stored models.OAuthAccessToken
+	if err := database.DB.Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", utils.HashToken(token), time.Now()).
+		First(&stored).Error; err != nil {
+		return invalidToken()
+	}
+
+	var user models.User
+	if err := database.DB.Preload("UserType").Where("cd_user = ?", stored.CdUser).First(&user).Error; err != nil {
+		return invalidToken()
+	}
+	if user.UserStatus != models.UserStatusActive {
+		return invalidToken()
+	}
+
+	return c.JSON(oidcUserClaims(&user, strings.Fields(stored.Scopes)))
+}
+
+// ListConnectedApps - List the partner portals the current user lets sign them in
+func ListConnectedApps(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var consents []models.OAuthConsent
+	if err := database.DB.Preload("Client").Where("cd_user = ?", userID).
+		Order("updated_at DESC").Find(&consents).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch connected apps",
+		})
+	}
+
+	result := make([]fiber.Map, 0, len(consents))
+	for _, consent := range consents {
+		result = append(result, fiber.Map{
+			"client_id":  consent.ClientID,
+			"name":       consent.Client.Name,
+			"logo_url":   consent.Client.LogoURL,
+			"scopes":     strings.Fields(consent.Scopes),
+			"granted_at": consent.CreatedAt,
+			"updated_at": consent.UpdatedAt,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"apps": result,
+	})
+}
+
+// RevokeConnectedApp - Withdraw consent from a partner portal and revoke its access tokens
+func RevokeConnectedApp(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	clientID := c.Params("clientId")
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("cd_user = ? AND client_id = ?", userID, clientID).Delete(&models.OAuthConsent{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Model(&models.OAuthAccessToken{}).
+			Where("cd_user = ? AND client_id = ? AND revoked_at IS NULL", userID, clientID).
+			Update("revoked_at", time.Now()).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "App not connected",
+		})
+	}
+	if err != nil {
+		log.Printf("Error revoking OAuth consent: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to disconnect app",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "App disconnected",
+	})
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	otpTTL               = 10 * time.Minute
+	otpResendCooldown    = 60 * time.Second
+	otpMaxFailedAttempts = 5
+	otpBaseLockout       = time.Minute
+	otpMaxLockout        = 24 * time.Hour
+)
+
+// otpError is a client-facing OTP failure with its HTTP status
+type otpError struct {
+	status     int
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *otpError) Error() string {
+	return e.message
+}
+
+var (
+	errOTPInvalid = &otpError{status: 400, message: "Invalid OTP code"}
+	errOTPExpired = &otpError{status: 400, message: "OTP has expired. Please request a new one."}
+)
+
+func otpLockedError(wait time.Duration) error {
+	return &otpError{status: 429, message: "Too many failed attempts. Please try again later.", retryAfter: wait}
+}
+
+// otpLockoutDuration doubles with every lockout, capped at otpMaxLockout
+func otpLockoutDuration(lockouts int) time.Duration {
+	d := otpBaseLockout
+	for i := 1; i < lockouts && d < otpMaxLockout; i++ {
+		d *= 2
+	}
+	if d > otpMaxLockout {
+		d = otpMaxLockout
+	}
+	return d
+}
+
+// lockThrottle loads the user's throttle row for purpose, creating it if needed, and locks it
+func lockThrottle(tx *gorm.DB, userID uint, purpose models.OTPPurpose) (*models.OTPThrottle, error) {
+	throttle := models.OTPThrottle{CdUser: userID, Purpose: purpose}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&throttle).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("cd_user = ? AND purpose = ?", userID, purpose).
+		First(&throttle).Error; err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// issueOTP creates a new code for purpose, replacing any outstanding one.
+// The plaintext code is returned for delivery and never stored.
+func issueOTP(user *models.User, purpose models.OTPPurpose) (string, error) {
+	code := utils.GenerateOTP()
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		throttle, err := lockThrottle(tx, user.CdUser, purpose)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+			return otpLockedError(throttle.LockedUntil.Sub(now))
+		}
+		if throttle.LastSentAt != nil {
+			if wait := otpResendCooldown - now.Sub(*throttle.LastSentAt); wait > 0 {
+				return &otpError{status: 429, message: "Please wait before requesting another code.", retryAfter: wait}
+			}
+		}
+
+		// Only the newest code for a purpose is valid
+		if err := tx.Where("cd_user = ? AND purpose = ?", user.CdUser, purpose).
+			Delete(&models.OTPChallenge{}).Error; err != nil {
+			return err
+		}
+
+		challenge := models.OTPChallenge{
+			CdUser:    user.CdUser,
+			Purpose:   purpose,
+			CodeHash:  utils.HashOTP(code),
+			ExpiresAt: now.Add(otpTTL),
+		}
+		if err := tx.Create(&challenge).Error; err != nil {
+			return err
+		}
+
+		throttle.LastSentAt = &now
+		return tx.Save(throttle).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// verifyOTP checks a code for purpose, consuming it on success and counting
+// failures towards an exponentially growing lockout
+func verifyOTP(user *models.User, purpose models.OTPPurpose, code string) error {
+	var result error
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		throttle, err := lockThrottle(tx, user.CdUser, purpose)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+			result = otpLockedError(throttle.LockedUntil.Sub(now))
+			return nil
+		}
+
+		var challenge models.OTPChallenge
+		err = tx.Where("cd_user = ? AND purpose = ? AND consumed_at IS NULL", user.CdUser, purpose).
+			Order("id DESC").
+			First(&challenge).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result = errOTPInvalid
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if now.After(challenge.ExpiresAt) {
+			result = errOTPExpired
+			return nil
+		}
+
+		if !utils.CheckOTP(code, challenge.CodeHash) {
+			challenge.Attempts++
+			result = recordOTPFailure(throttle, now)
+			if result != errOTPInvalid {
+				// Locked out: burn the code so guessing has to start over with a new one
+				challenge.ConsumedAt = &now
+			}
+
+			// Persist the failure; the caller still sees result
+			if err := tx.Save(&challenge).Error; err != nil {
+				return err
+			}
+			return tx.Save(throttle).Error
+		}
+
+		challenge.ConsumedAt = &now
+		resetOTPThrottle(throttle)
+		if err := tx.Save(&challenge).Error; err != nil {
+			return err
+		}
+		return tx.Save(throttle).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return result
+}
+
+// recordOTPFailure counts a failed guess, locking the throttle with
+// exponential backoff once too many failures accumulate
+func recordOTPFailure(throttle *models.OTPThrottle, now time.Time) error {
+	throttle.FailedAttempts++
+	if throttle.FailedAttempts < otpMaxFailedAttempts {
+		return errOTPInvalid
+	}
+
+	throttle.LockoutCount++
+	until := now.Add(otpLockoutDuration(throttle.LockoutCount))
+	throttle.LockedUntil = &until
+	throttle.FailedAttempts = 0
+	return otpLockedError(until.Sub(now))
+}
+
+// resetOTPThrottle clears failures after a successful verification
+func resetOTPThrottle(throttle *models.OTPThrottle) {
+	throttle.FailedAttempts = 0
+	throttle.LockoutCount = 0
+	throttle.LockedUntil = nil
+}
+
+// otpErrorResponse renders an OTP failure, falling back to a 500 for unexpected errors
+func otpErrorResponse(c *fiber.Ctx, err error, fallback string) error {
+	var oe *otpError
+	if !errors.As(err, &oe) {
+		log.Printf("OTP error: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": fallback,
+		})
+	}
+
+	body := fiber.Map{
+		"error": oe.message,
+	}
+	if oe.retryAfter > 0 {
+		seconds := int(oe.retryAfter.Round(time.Second).Seconds())
+		if seconds < 1 {
+			seconds = 1
+		}
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		body["retry_after"] = seconds
+	}
+
+	return c.Status(oe.status).JSON(body)
+}
@@ -0,0 +1,624 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/utils"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	passkeyCeremonyTTL = 5 * time.Minute
+	maxPasskeysPerUser = 10
+)
+
+var (
+	errCeremonyNotFound = errors.New("passkey ceremony not found or expired")
+	errPasskeyCloned    = errors.New("passkey signature counter went backwards")
+	errPasskeyUnknown   = errors.New("passkey not registered")
+)
+
+type PasskeyRegistrationRequest struct {
+	CeremonyID string          `json:"ceremony_id" validate:"required"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type PasskeyLoginRequest struct {
+	CeremonyID string          `json:"ceremony_id" validate:"required"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type PasskeyMFARequest struct {
+	MFAToken   string          `json:"mfa_token" validate:"required"`
+	CeremonyID string          `json:"ceremony_id" validate:"required"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+// passkeyUser presents a user and their stored passkeys to the WebAuthn library
+type passkeyUser struct {
+	user        *models.User
+	credentials []models.WebAuthnCredential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return utils.WebAuthnUserHandle(u.user.CdUser)
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	if name := strings.TrimSpace(u.user.FirstName + " " + u.user.LastName); name != "" {
+		return name
+	}
+	return u.user.Email
+}
+
+func (u *passkeyUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, stored := range u.credentials {
+		var transports []protocol.AuthenticatorTransport
+		for _, t := range strings.Split(stored.Transports, ",") {
+			if t != "" {
+				transports = append(transports, protocol.AuthenticatorTransport(t))
+			}
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              stored.CredentialID,
+			PublicKey:       stored.PublicKey,
+			AttestationType: stored.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				UserVerified:   stored.UserVerified,
+				BackupEligible: stored.BackupEligible,
+				BackupState:    stored.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    stored.AAGUID,
+				SignCount: stored.SignCount,
+			},
+		})
+	}
+	return credentials
+}
+
+// exclusions lists the user's passkeys so an authenticator is not registered twice
+func (u *passkeyUser) exclusions() []protocol.CredentialDescriptor {
+	var descriptors []protocol.CredentialDescriptor
+	for _, credential := range u.WebAuthnCredentials() {
+		descriptors = append(descriptors, credential.Descriptor())
+	}
+	return descriptors
+}
+
+// loadPasskeyUser loads the passkeys the user can sign in with; ones flagged as
+// possibly cloned stay listed for the user but are no longer accepted
+func loadPasskeyUser(user *models.User) (*passkeyUser, error) {
+	var credentials []models.WebAuthnCredential
+	if err := database.DB.Where("cd_user = ? AND NOT clone_warning", user.CdUser).Order("id").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return &passkeyUser{user: user, credentials: credentials}, nil
+}
+
+// saveCeremony stores the challenge the browser must sign and returns its ID
+func saveCeremony(userID *uint, kind string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	// Abandoned ceremonies are cleared as new ones start
+	if err := database.DB.Where("expires_at < ?", now).Delete(&models.WebAuthnCeremony{}).Error; err != nil {
+		log.Printf("Error clearing expired passkey ceremonies: %v", err)
+	}
+
+	ceremony := models.WebAuthnCeremony{
+		ID:          uuid.NewString(),
+		CdUser:      userID,
+		Kind:        kind,
+		SessionData: string(data),
+		ExpiresAt:   now.Add(passkeyCeremonyTTL),
+	}
+	if err := database.DB.Create(&ceremony).Error; err != nil {
+		return "", err
+	}
+	return ceremony.ID, nil
+}
+
+// takeCeremony deletes and returns a ceremony, so each challenge is checked once.
+// userID must match the ceremony's user; nil for login without a known user.
+func takeCeremony(id, kind string, userID *uint) (*webauthn.SessionData, error) {
+	query := database.DB.Clauses(clause.Returning{}).Where("id = ? AND kind = ?", id, kind)
+	if userID != nil {
+		query = query.Where("cd_user = ?", *userID)
+	} else {
+		query = query.Where("cd_user IS NULL")
+	}
+
+	var ceremony models.WebAuthnCeremony
+	result := query.Delete(&ceremony)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(ceremony.ExpiresAt) {
+		return nil, errCeremonyNotFound
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(ceremony.SessionData), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// recordPasskeyUse stores the new signature counter and flags after a login.
+// A counter that did not advance marks the passkey as possibly cloned and
+// rejects the login.
+func recordPasskeyUse(userID uint, credential *webauthn.Credential) error {
+	query := database.DB.Model(&models.WebAuthnCredential{}).
+		Where("cd_user = ? AND credential_id = ?", userID, credential.ID)
+
+	if credential.Authenticator.CloneWarning {
+		if err := query.Update("clone_warning", true).Error; err != nil {
+			log.Printf("Error flagging passkey: %v", err)
+		}
+		log.Printf("⚠️  Passkey of user %d presented a stale signature counter", userID)
+		return errPasskeyCloned
+	}
+
+	return query.Updates(map[string]interface{}{
+		"sign_count":    credential.Authenticator.SignCount,
+		"user_verified": credential.Flags.UserVerified,
+		"backup_state":  credential.Flags.BackupState,
+		"last_used_at":  time.Now(),
+	}).Error
+}
+
+// passkeyErrorResponse renders failures of the passkey ceremonies
+func passkeyErrorResponse(c *fiber.Ctx, err error, fallback string) error {
+	var protocolErr *protocol.Error
+	switch {
+	case errors.Is(err, utils.ErrWebAuthnDisabled):
+		return c.Status(503).JSON(fiber.Map{
+			"error": "Passkeys are not enabled",
+		})
+	case errors.Is(err, errInvalidMFAToken):
+		return mfaErrorResponse(c, err, fallback)
+	case errors.Is(err, errCeremonyNotFound):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Passkey request expired. Please try again.",
+		})
+	case errors.Is(err, errPasskeyCloned):
+		return c.Status(401).JSON(fiber.Map{
+			"error": "This passkey may have been copied and has been blocked. Please use another sign-in method.",
+		})
+	case errors.Is(err, errPasskeyUnknown), errors.As(err, &protocolErr):
+		if protocolErr != nil {
+			log.Printf("Passkey rejected: %s (%s)", protocolErr.Details, protocolErr.DevInfo)
+		}
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Passkey could not be verified",
+		})
+	}
+
+	log.Printf("Passkey error: %v", err)
+	return c.Status(500).JSON(fiber.Map{
+		"error": fallback,
+	})
+}
+
+// passkeyFailureReason names why a passkey login failed in the login history
+func passkeyFailureReason(err error) string {
+	switch {
+	case errors.Is(err, errCeremonyNotFound):
+		return "challenge_expired"
+	case errors.Is(err, errPasskeyCloned):
+		return "cloned_passkey"
+	case errors.Is(err, errPasskeyUnknown):
+		return "unknown_passkey"
+	}
+	return "invalid_assertion"
+}
+
+// ListPasskeys - The current user's registered passkeys
+func ListPasskeys(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var passkeys []models.WebAuthnCredential
+	if err := database.DB.Where("cd_user = ?", userID).Order("id").Find(&passkeys).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch passkeys",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"passkeys": passkeys,
+	})
+}
+
+// BeginPasskeyRegistration - Options for the browser to create a new passkey
+func BeginPasskeyRegistration(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	rp, err := utils.WebAuthn()
+	if err != nil {
+		return passkeyErrorResponse(c, err, "")
+	}
+
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", userID).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	pu, err := loadPasskeyUser(&user)
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to start passkey registration")
+	}
+	if len(pu.credentials) >= maxPasskeysPerUser {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Too many passkeys. Remove one before adding another.",
+		})
+	}
+
+	options, session, err := rp.BeginRegistration(pu,
+		webauthn.WithExclusions(pu.exclusions()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to start passkey registration")
+	}
+
+	ceremonyID, err := saveCeremony(&userID, models.WebAuthnCeremonyRegistration, session)
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to start passkey registration")
+	}
+
+	return c.JSON(fiber.Map{
+		"ceremony_id": ceremonyID,
+		"options":     options,
+	})
+}
+
+// FinishPasskeyRegistration - Store the passkey the browser created
+func FinishPasskeyRegistration(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req PasskeyRegistrationRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	rp, err := utils.WebAuthn()
+	if err != nil {
+		return passkeyErrorResponse(c, err, "")
+	}
+
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", userID).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	session, err := takeCeremony(req.CeremonyID, models.WebAuthnCeremonyRegistration, &userID)
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to register passkey")
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(req.Credential))
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to register passkey")
+	}
+
+	pu, err := loadPasskeyUser(&user)
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to register passkey")
+	}
+	credential, err := rp.CreateCredential(pu, *session, parsed)
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to register passkey")
+	}
+
+	var transports []string
+	for _, t := range credential.Transport {
+		transports = append(transports, string(t))
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = utils.DescribeUserAgent(c.Get(fiber.HeaderUserAgent))
+	}
+
+	passkey := models.WebAuthnCredential{
+		CdUser:          userID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      truncateString(strings.Join(transports, ","), 128),
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		UserVerified:    credential.Flags.UserVerified,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Name:            truncateString(name, 64),
+	}
+	if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&passkey).Error; err != nil {
+		return passkeyErrorResponse(c, err, "Failed to register passkey")
+	}
+	if passkey.ID == 0 {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "This passkey is already registered",
+		})
+	}
+
+	log.Printf("🔑 Passkey %d registered for user %d", passkey.ID, userID)
+
+	return c.Status(201).JSON(fiber.Map{
+		"message": "Passkey registered",
+		"passkey": passkey,
+	})
+}
+
+// DeletePasskey - Remove one of the current user's passkeys
+func DeletePasskey(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	passkeyID, err := c.ParamsInt("id")
+	if err != nil || passkeyID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid passkey ID",
+		})
+	}
+
+	// Users who must use two-factor authentication keep at least one method
+	if appConfig.MFA.IsRequiredFor(c.Locals("userType").(int)) {
+		totp, err := loadTOTP(database.DB, userID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		var others int64
+		if err := database.DB.Model(&models.WebAuthnCredential{}).
+			Where("cd_user = ? AND id <> ? AND NOT clone_warning", userID, passkeyID).
+			Count(&others).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		if others == 0 && (totp == nil || totp.ConfirmedAt == nil) {
+			return c.Status(403).JSON(fiber.Map{
+				"error": "Two-factor authentication is required for your account. Set up an authenticator app before removing your last passkey.",
+			})
+		}
+	}
+
+	result := database.DB.Where("id = ? AND cd_user = ?", passkeyID, userID).Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to remove passkey",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Passkey not found",
+		})
+	}
+
+	log.Printf("🔑 Passkey %d removed by user %d", passkeyID, userID)
+
+	return c.JSON(fiber.Map{
+		"message": "Passkey removed",
+	})
+}
+
+// BeginPasskeyLogin - Options for the browser to sign in with any passkey for this site
+func BeginPasskeyLogin(c *fiber.Ctx) error {
+	rp, err := utils.WebAuthn()
+	if err != nil {
+		return passkeyErrorResponse(c, err, "")
+	}
+
+	// A passkey on its own must prove both possession and the user's PIN or biometric
+	options, session, err := rp.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to start passkey login")
+	}
+
+	ceremonyID, err := saveCeremony(nil, models.WebAuthnCeremonyLogin, session)
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to start passkey login")
+	}
+
+	return c.JSON(fiber.Map{
+		"ceremony_id": ceremonyID,
+		"options":     options,
+	})
+}
+
+// FinishPasskeyLogin - Log in with a passkey; no password or second factor is needed
+func FinishPasskeyLogin(c *fiber.Ctx) error {
+	var req PasskeyLoginRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	rp, err := utils.WebAuthn()
+	if err != nil {
+		return passkeyErrorResponse(c, err, "")
+	}
+
+	// user is set once the passkey is matched, so failures can be attributed
+	var user *models.User
+	fail := func(err error) error {
+		recordLogin(c, models.LoginEventPasskey, models.LoginOutcomeFailure, passkeyFailureReason(err), user, "")
+		return passkeyErrorResponse(c, err, "Failed to log in with passkey")
+	}
+
+	session, err := takeCeremony(req.CeremonyID, models.WebAuthnCeremonyLogin, nil)
+	if err != nil {
+		return fail(err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(req.Credential))
+	if err != nil {
+		return fail(err)
+	}
+
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		var stored models.WebAuthnCredential
+		if err := database.DB.Where("credential_id = ?", rawID).First(&stored).Error; err != nil {
+			return nil, errPasskeyUnknown
+		}
+
+		var owner models.User
+		if err := database.DB.Preload("UserType").Where("cd_user = ?", stored.CdUser).First(&owner).Error; err != nil {
+			return nil, errPasskeyUnknown
+		}
+		user = &owner
+		return loadPasskeyUser(&owner)
+	}
+
+	credential, err := rp.ValidateDiscoverableLogin(findUser, *session, parsed)
+	if err != nil {
+		if user == nil {
+			err = errPasskeyUnknown
+		}
+		return fail(err)
+	}
+	if err := recordPasskeyUse(user.CdUser, credential); err != nil {
+		return fail(err)
+	}
+
+	if errResp := inactiveAccountResponse(c, user); errResp != nil {
+		recordLogin(c, models.LoginEventPasskey, models.LoginOutcomeFailure, inactiveReason(user), user, "")
+		return errResp()
+	}
+
+	recordLogin(c, models.LoginEventPasskey, models.LoginOutcomeSuccess, "", user, "")
+
+	return respondWithSession(c, user, "Login successful")
+}
+
+// BeginPasskeyMFA - Options for the browser to confirm a password login with a passkey
+func BeginPasskeyMFA(c *fiber.Ctx) error {
+	var req MFATokenRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	rp, err := utils.WebAuthn()
+	if err != nil {
+		return passkeyErrorResponse(c, err, "")
+	}
+
+	user, err := loadMFAUser(req.MFAToken)
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to start passkey verification")
+	}
+
+	pu, err := loadPasskeyUser(user)
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to start passkey verification")
+	}
+	if len(pu.credentials) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "No passkeys are registered for this account",
+		})
+	}
+
+	options, session, err := rp.BeginLogin(pu)
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to start passkey verification")
+	}
+
+	ceremonyID, err := saveCeremony(&user.CdUser, models.WebAuthnCeremonyMFA, session)
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to start passkey verification")
+	}
+
+	return c.JSON(fiber.Map{
+		"ceremony_id": ceremonyID,
+		"options":     options,
+	})
+}
+
+// FinishPasskeyMFA - Second login step: exchange an mfa_token and passkey assertion for a session
+func FinishPasskeyMFA(c *fiber.Ctx) error {
+	var req PasskeyMFARequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	rp, err := utils.WebAuthn()
+	if err != nil {
+		return passkeyErrorResponse(c, err, "")
+	}
+
+	user, err := loadMFAUser(req.MFAToken)
+	if err != nil {
+		recordLogin(c, models.LoginEventMFA, models.LoginOutcomeFailure, loginFailureReason(err), nil, "")
+		return passkeyErrorResponse(c, err, "Failed to verify passkey")
+	}
+
+	fail := func(err error) error {
+		recordLogin(c, models.LoginEventMFA, models.LoginOutcomeFailure, passkeyFailureReason(err), user, "")
+		return passkeyErrorResponse(c, err, "Failed to verify passkey")
+	}
+
+	session, err := takeCeremony(req.CeremonyID, models.WebAuthnCeremonyMFA, &user.CdUser)
+	if err != nil {
+		return fail(err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(req.Credential))
+	if err != nil {
+		return fail(err)
+	}
+
+	pu, err := loadPasskeyUser(user)
+	if err != nil {
+		return passkeyErrorResponse(c, err, "Failed to verify passkey")
+	}
+	credential, err := rp.ValidateLogin(pu, *session, parsed)
+	if err != nil {
+		return fail(err)
+	}
+	if err := recordPasskeyUse(user.CdUser, credential); err != nil {
+		return fail(err)
+	}
+
+	recordLogin(c, models.LoginEventMFA, models.LoginOutcomeSuccess, "passkey", user, "")
+
+	return respondWithSession(c, user, "Login successful")
+}
+
+// hasPasskey reports whether the user has a passkey they can still sign in
+// with; ones flagged as possibly cloned do not count
+func hasPasskey(tx *gorm.DB, userID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.WebAuthnCredential{}).Where("cd_user = ? AND NOT clone_warning", userID).Count(&count).Error
+	return count > 0, err
+}
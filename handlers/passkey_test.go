@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+var b64 = base64.RawURLEncoding
+
+// softAuthenticator is an in-process ES256 passkey. It answers the options
+// the server hands out the way a browser and platform authenticator would.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate passkey: %v", err)
+	}
+	credentialID := make([]byte, 32)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatalf("generate credential ID: %v", err)
+	}
+	return &softAuthenticator{key: key, credentialID: credentialID}
+}
+
+// authenticatorData builds the signed authenticator data; the public key is
+// attached during registration
+func (a *softAuthenticator) authenticatorData(t *testing.T, flags byte) []byte {
+	t.Helper()
+
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if flags&flagAttested == 0 {
+		return data
+	}
+
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.PublicKey.X.FillBytes(x)
+	a.key.PublicKey.Y.FillBytes(y)
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: x,
+		YCoord: y,
+	})
+	if err != nil {
+		t.Fatalf("encode public key: %v", err)
+	}
+
+	data = append(data, make([]byte, 16)...) // AAGUID
+	data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+	data = append(data, a.credentialID...)
+	return append(data, publicKey...)
+}
+
+func clientDataJSON(t *testing.T, ceremony, challenge string) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatalf("encode client data: %v", err)
+	}
+	return data
+}
+
+// publicKeyOptions pulls the publicKey member out of a begin response
+func publicKeyOptions(t *testing.T, body map[string]interface{}) map[string]interface{} {
+	t.Helper()
+
+	options, _ := body["options"].(map[string]interface{})
+	publicKey, ok := options["publicKey"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("response has no publicKey options: %v", body)
+	}
+	return publicKey
+}
+
+// create answers registration options with a "none" attestation
+func (a *softAuthenticator) create(t *testing.T, body map[string]interface{}) json.RawMessage {
+	t.Helper()
+
+	options := publicKeyOptions(t, body)
+	challenge, _ := options["challenge"].(string)
+	user, _ := options["user"].(map[string]interface{})
+	userID, _ := user["id"].(string)
+	handle, err := b64.DecodeString(userID)
+	if err != nil {
+		t.Fatalf("decode user handle %q: %v", userID, err)
+	}
+	a.userHandle = handle
+
+	attestation, err := webauthncbor.Marshal(struct {
+		Format    string                 `cbor:"fmt"`
+		Statement map[string]interface{} `cbor:"attStmt"`
+		AuthData  []byte                 `cbor:"authData"`
+	}{
+		Format:    "none",
+		Statement: map[string]interface{}{},
+		AuthData:  a.authenticatorData(t, flagUserPresent|flagUserVerified|flagAttested),
+	})
+	if err != nil {
+		t.Fatalf("encode attestation: %v", err)
+	}
+
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    b64.EncodeToString(clientDataJSON(t, "webauthn.create", challenge)),
+		"attestationObject": b64.EncodeToString(attestation),
+	})
+}
+
+// get signs login options after advancing the signature counter by step
+func (a *softAuthenticator) get(t *testing.T, body map[string]interface{}, step int) json.RawMessage {
+	t.Helper()
+
+	challenge, _ := publicKeyOptions(t, body)["challenge"].(string)
+	a.signCount = uint32(int(a.signCount) + step)
+
+	authData := a.authenticatorData(t, flagUserPresent|flagUserVerified)
+	clientData := clientDataJSON(t, "webauthn.get", challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("sign assertion: %v", err)
+	}
+
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    b64.EncodeToString(clientData),
+		"authenticatorData": b64.EncodeToString(authData),
+		"signature":         b64.EncodeToString(signature),
+		"userHandle":        b64.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) credential(t *testing.T, response map[string]string) json.RawMessage {
+	t.Helper()
+
+	id := b64.EncodeToString(a.credentialID)
+	data, err := json.Marshal(map[string]interface{}{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatalf("encode credential: %v", err)
+	}
+	return data
+}
+
+func newPasskeyTestApp(user *models.User) *fiber.App {
+	app := fiber.New()
+
+	auth := app.Group("/auth")
+	auth.Post("/login", Login)
+	auth.Post("/login/2fa/setup", SetupLoginTOTP)
+	auth.Post("/login/2fa/confirm", ConfirmLoginTOTP)
+	auth.Post("/login/2fa/passkey/begin", BeginPasskeyMFA)
+	auth.Post("/login/2fa/passkey/finish", FinishPasskeyMFA)
+	auth.Post("/passkey/begin", BeginPasskeyLogin)
+	auth.Post("/passkey/finish", FinishPasskeyLogin)
+
+	mfa := app.Group("/2fa", asUser(user))
+	mfa.Post("/passkeys/begin", BeginPasskeyRegistration)
+	mfa.Post("/passkeys/finish", FinishPasskeyRegistration)
+
+	return app
+}
+
+// registerPasskey enrols a software authenticator for the app's user
+func registerPasskey(t *testing.T, app *fiber.App) *softAuthenticator {
+	t.Helper()
+
+	status, begin := postJSON(t, app, "/2fa/passkeys/begin", fiber.Map{})
+	if status != 200 {
+		t.Fatalf("begin registration: status %d, body %v", status, begin)
+	}
+
+	authenticator := newSoftAuthenticator(t)
+	status, finish := postJSON(t, app, "/2fa/passkeys/finish", fiber.Map{
+		"ceremony_id": begin["ceremony_id"],
+		"name":        "Test key",
+		"credential":  authenticator.create(t, begin),
+	})
+	if status != 201 {
+		t.Fatalf("finish registration: status %d, body %v", status, finish)
+	}
+	return authenticator
+}
+
+// loginWithPasskey runs a passwordless passkey login, advancing the counter by step
+func loginWithPasskey(t *testing.T, app *fiber.App, authenticator *softAuthenticator, step int) (int, map[string]interface{}) {
+	t.Helper()
+
+	status, begin := postJSON(t, app, "/auth/passkey/begin", fiber.Map{})
+	if status != 200 {
+		t.Fatalf("begin passkey login: status %d, body %v", status, begin)
+	}
+
+	return postJSON(t, app, "/auth/passkey/finish", fiber.Map{
+		"ceremony_id": begin["ceremony_id"],
+		"credential":  authenticator.get(t, begin, step),
+	})
+}
+
+func storedPasskey(t *testing.T, userID uint) models.WebAuthnCredential {
+	t.Helper()
+
+	var passkey models.WebAuthnCredential
+	if err := database.DB.Where("cd_user = ?", userID).First(&passkey).Error; err != nil {
+		t.Fatalf("load passkey: %v", err)
+	}
+	return passkey
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "patient@vcm.test", models.UserTypePatient)
+	app := newPasskeyTestApp(user)
+
+	authenticator := registerPasskey(t, app)
+	if passkey := storedPasskey(t, user.CdUser); passkey.SignCount != 0 || !passkey.UserVerified {
+		t.Fatalf("stored passkey = %+v, want counter 0 and user verified", passkey)
+	}
+
+	for _, want := range []uint32{1, 5} {
+		status, body := loginWithPasskey(t, app, authenticator, int(want)-int(authenticator.signCount))
+		if status != 200 {
+			t.Fatalf("passkey login: status %d, body %v", status, body)
+		}
+		if body["token"] == nil || body["refresh_token"] == nil {
+			t.Fatalf("passkey login returned no session: %v", body)
+		}
+		if got := storedPasskey(t, user.CdUser).SignCount; got != want {
+			t.Fatalf("sign count = %d, want %d", got, want)
+		}
+	}
+}
+
+func TestPasskeyLoginRejectsRegressedCounter(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "patient@vcm.test", models.UserTypePatient)
+	app := newPasskeyTestApp(user)
+
+	authenticator := registerPasskey(t, app)
+	if status, body := loginWithPasskey(t, app, authenticator, 5); status != 200 {
+		t.Fatalf("passkey login: status %d, body %v", status, body)
+	}
+
+	// A copy of the key that has signed fewer times than the original
+	if status, body := loginWithPasskey(t, app, authenticator, -2); status != 401 {
+		t.Fatalf("regressed counter: status %d, body %v, want 401", status, body)
+	}
+	passkey := storedPasskey(t, user.CdUser)
+	if !passkey.CloneWarning || passkey.SignCount != 5 {
+		t.Fatalf("stored passkey = %+v, want clone warning and counter 5", passkey)
+	}
+
+	// The flagged passkey stays blocked even with a counter ahead of the stored one
+	if status, body := loginWithPasskey(t, app, authenticator, 10); status != 401 {
+		t.Fatalf("flagged passkey: status %d, body %v, want 401", status, body)
+	}
+}
+
+func TestPasskeyAsSecondFactor(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "doctor@vcm.test", models.UserTypeDoctor)
+	app := newPasskeyTestApp(user)
+
+	authenticator := registerPasskey(t, app)
+
+	login := loginWithPassword(t, app, user.Email)
+	if login["mfa_required"] != true || login["mfa_enrollment_required"] != false {
+		t.Fatalf("password login = %v, want a passkey challenge", login)
+	}
+
+	status, begin := postJSON(t, app, "/auth/login/2fa/passkey/begin", fiber.Map{
+		"mfa_token": login["mfa_token"],
+	})
+	if status != 200 {
+		t.Fatalf("begin passkey verification: status %d, body %v", status, begin)
+	}
+	status, body := postJSON(t, app, "/auth/login/2fa/passkey/finish", fiber.Map{
+		"mfa_token":   login["mfa_token"],
+		"ceremony_id": begin["ceremony_id"],
+		"credential":  authenticator.get(t, begin, 1),
+	})
+	if status != 200 || body["token"] == nil {
+		t.Fatalf("finish passkey verification: status %d, body %v", status, body)
+	}
+	if got := storedPasskey(t, user.CdUser).SignCount; got != 1 {
+		t.Fatalf("sign count = %d, want 1", got)
+	}
+
+	// The challenge is spent once checked
+	status, body = postJSON(t, app, "/auth/login/2fa/passkey/finish", fiber.Map{
+		"mfa_token":   login["mfa_token"],
+		"ceremony_id": begin["ceremony_id"],
+		"credential":  authenticator.get(t, begin, 1),
+	})
+	if status != 400 {
+		t.Fatalf("replayed ceremony: status %d, body %v, want 400", status, body)
+	}
+}
+
+func TestLoginEnrollmentRefusedWithPasskey(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "doctor@vcm.test", models.UserTypeDoctor)
+	app := newPasskeyTestApp(user)
+
+	registerPasskey(t, app)
+	login := loginWithPassword(t, app, user.Email)
+
+	// Knowing the password must not be enough to swap in a new authenticator
+	status, body := postJSON(t, app, "/auth/login/2fa/setup", fiber.Map{
+		"mfa_token": login["mfa_token"],
+	})
+	if status != 403 {
+		t.Fatalf("setup with a passkey: status %d, body %v, want 403", status, body)
+	}
+	status, body = postJSON(t, app, "/auth/login/2fa/confirm", fiber.Map{
+		"mfa_token": login["mfa_token"],
+		"code":      "123456",
+	})
+	if status != 403 {
+		t.Fatalf("confirm with a passkey: status %d, body %v, want 403", status, body)
+	}
+
+	var codes int64
+	database.DB.Model(&models.MFARecoveryCode{}).Where("cd_user = ?", user.CdUser).Count(&codes)
+	if codes != 0 {
+		t.Fatalf("%d recovery codes issued, want none", codes)
+	}
+}
+
+func TestLoginEnrollmentAllowedWithoutSecondFactor(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "doctor@vcm.test", models.UserTypeDoctor)
+	app := newPasskeyTestApp(user)
+
+	login := loginWithPassword(t, app, user.Email)
+	if login["mfa_enrollment_required"] != true {
+		t.Fatalf("password login = %v, want enrolment required", login)
+	}
+
+	status, body := postJSON(t, app, "/auth/login/2fa/setup", fiber.Map{
+		"mfa_token": login["mfa_token"],
+	})
+	if status != 200 {
+		t.Fatalf("setup without a second factor: status %d, body %v", status, body)
+	}
+}
+
+func TestLoginEnrollmentRefusedWhenNotRequired(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "patient@vcm.test", models.UserTypePatient)
+	app := newPasskeyTestApp(user)
+
+	// Patients with a passkey get an mfa token, but never an enrolment step
+	registerPasskey(t, app)
+	login := loginWithPassword(t, app, user.Email)
+
+	status, body := postJSON(t, app, "/auth/login/2fa/setup", fiber.Map{
+		"mfa_token": login["mfa_token"],
+	})
+	if status != 403 {
+		t.Fatalf("setup for optional MFA: status %d, body %v, want 403", status, body)
+	}
+}
+
+func TestFlaggedPasskeyFallsBackToEnrollment(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "doctor@vcm.test", models.UserTypeDoctor)
+	app := newPasskeyTestApp(user)
+
+	authenticator := registerPasskey(t, app)
+	if status, body := loginWithPasskey(t, app, authenticator, 5); status != 200 {
+		t.Fatalf("passkey login: status %d, body %v", status, body)
+	}
+	if status, body := loginWithPasskey(t, app, authenticator, -2); status != 401 {
+		t.Fatalf("regressed counter: status %d, body %v, want 401", status, body)
+	}
+
+	// With its only passkey blocked the account must be able to set up another factor
+	login := loginWithPassword(t, app, user.Email)
+	if login["mfa_enrollment_required"] != true {
+		t.Fatalf("password login = %v, want enrolment required", login)
+	}
+	status, body := postJSON(t, app, "/auth/login/2fa/setup", fiber.Map{
+		"mfa_token": login["mfa_token"],
+	})
+	if status != 200 {
+		t.Fatalf("setup after the passkey was flagged: status %d, body %v", status, body)
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/notify"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Email       string `json:"email" validate:"required,email"`
+	OTP         string `json:"otp" validate:"required,len=6"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// ForgotPassword - Email a reset code without revealing whether the account exists
+func ForgotPassword(c *fiber.Ctx) error {
+	var req ForgotPasswordRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	var user models.User
+	err := database.DB.Where("email = ?", req.Email).First(&user).Error
+	if err == nil {
+		otpCode, err := issueOTP(&user, models.OTPPurposeReset)
+		if err != nil {
+			// Cooldowns and lockouts are not reported, to avoid revealing the account
+			log.Printf("Reset code not issued for user %d: %v", user.CdUser, err)
+		} else {
+			// Send in the background so response time does not reveal the account
+			go func(user models.User) {
+				if err := notify.SendOTP(context.Background(), &user, models.OTPPurposeReset, otpCode); err != nil {
+					log.Printf("Error sending reset code: %v", err)
+				}
+			}(user)
+		}
+	} else if err != gorm.ErrRecordNotFound {
+		log.Printf("Error looking up user for reset: %v", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "If an account exists for this email, a reset code has been sent.",
+	})
+}
+
+// ResetPassword - Set a new password using the emailed code and end all sessions
+func ResetPassword(c *fiber.Ctx) error {
+	var req ResetPasswordRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	if err := utils.ValidatePassword(req.NewPassword, req.Email); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Unknown email and wrong code look identical to the caller
+	var user models.User
+	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid or expired reset code",
+		})
+	}
+
+	// Lockouts are reported like a wrong code, since unknown emails never lock
+	if err := verifyOTP(&user, models.OTPPurposeReset, req.OTP); err != nil {
+		var oe *otpError
+		if errors.As(err, &oe) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid or expired reset code",
+			})
+		}
+		return otpErrorResponse(c, err, "Failed to reset password")
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to process password",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		user.Password = hashedPassword
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+
+		return revokeAllCredentials(tx, user.CdUser)
+	})
+	if err != nil {
+		log.Printf("Error resetting password: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to reset password",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password has been reset. Please log in with your new password.",
+	})
+}
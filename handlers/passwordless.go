@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/notify"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const loginLinkTokenPurpose = "login_link"
+
+var errLoginLinkUsed = errors.New("login link already used")
+
+type PasswordlessStartRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type PasswordlessCodeRequest struct {
+	Email       string `json:"email" validate:"required,email"`
+	OTP         string `json:"otp" validate:"required,len=6"`
+	DeviceToken string `json:"device_token" validate:"required"`
+}
+
+type PasswordlessLinkRequest struct {
+	Token       string `json:"token" validate:"required"`
+	DeviceToken string `json:"device_token" validate:"required"`
+}
+
+// loginLinkClaims is the signed payload carried in a magic link
+type loginLinkClaims struct {
+	UserID    uint   `json:"uid"`
+	ExpiresAt int64  `json:"exp"`
+	Nonce     string `json:"n"`
+}
+
+// sameDevice reports whether deviceToken is the one handed out with the link
+func sameDevice(link *models.LoginLink, deviceToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(utils.HashToken(deviceToken)), []byte(link.DeviceHash)) == 1
+}
+
+// sendLoginLink issues a code and a signed link for user, bound to the
+// device token the caller received, replacing any unused earlier link
+func sendLoginLink(c *fiber.Ctx, user *models.User, deviceHash string) error {
+	code, err := issueOTP(user, models.OTPPurposeLogin)
+	if err != nil {
+		return err
+	}
+
+	ttl := appConfig.Passwordless.TTL
+	expiresAt := time.Now().Add(ttl)
+	token, err := utils.SignPayload(loginLinkTokenPurpose, loginLinkClaims{
+		UserID:    user.CdUser,
+		ExpiresAt: expiresAt.Unix(),
+		Nonce:     uuid.NewString(),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the newest link is valid, like the code sent with it
+		if err := tx.Where("cd_user = ? AND consumed_at IS NULL", user.CdUser).
+			Delete(&models.LoginLink{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.LoginLink{
+			CdUser:     user.CdUser,
+			TokenHash:  utils.HashToken(token),
+			DeviceHash: deviceHash,
+			IPAddress:  c.IP(),
+			ExpiresAt:  expiresAt,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(appConfig.FrontendURL, "/") + "/login/link?token=" + url.QueryEscape(token)
+	// Send in the background so response time does not reveal the account
+	go func(user models.User) {
+		if err := notify.SendLoginLink(context.Background(), &user, code, link, ttl); err != nil {
+			log.Printf("Error sending login link: %v", err)
+		}
+	}(*user)
+	return nil
+}
+
+// redeemLoginLink uses up the link and logs the user in, continuing with the
+// second factor where the account has one
+func redeemLoginLink(c *fiber.Ctx, user *models.User, link *models.LoginLink) error {
+	if errResp := inactiveAccountResponse(c, user); errResp != nil {
+		recordLogin(c, models.LoginEventPasswordless, models.LoginOutcomeFailure, inactiveReason(user), user, "")
+		return errResp()
+	}
+	if !appConfig.Passwordless.IsAllowedFor(user.TyUser) {
+		recordLogin(c, models.LoginEventPasswordless, models.LoginOutcomeFailure, "not_allowed", user, "")
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Passwordless login is not available for this account",
+		})
+	}
+
+	result := database.DB.Model(&models.LoginLink{}).
+		Where("id = ? AND consumed_at IS NULL", link.ID).
+		Update("consumed_at", time.Now())
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = errLoginLinkUsed
+	}
+	if result.Error != nil {
+		if !errors.Is(result.Error, errLoginLinkUsed) {
+			log.Printf("Error redeeming login link: %v", result.Error)
+		}
+		recordLogin(c, models.LoginEventPasswordless, models.LoginOutcomeFailure, "link_used", user, "")
+		return c.Status(400).JSON(fiber.Map{
+			"error": "This login link has already been used",
+		})
+	}
+
+	recordLogin(c, models.LoginEventPasswordless, models.LoginOutcomeSuccess, "", user, "")
+
+	// Privileged accounts continue with their second factor
+	return finishLogin(c, user, "Login successful")
+}
+
+// StartPasswordlessLogin - Email a login link and code without revealing whether the account exists
+func StartPasswordlessLogin(c *fiber.Ctx) error {
+	var req PasswordlessStartRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	// Every caller gets a device token so the response does not reveal the account
+	deviceToken, deviceHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	var user models.User
+	err = database.DB.Where("email = ?", req.Email).First(&user).Error
+	switch {
+	case err == nil && !appConfig.Passwordless.IsAllowedFor(user.TyUser):
+		log.Printf("Passwordless login not allowed for user %d (type %d)", user.CdUser, user.TyUser)
+	case err == nil:
+		// Cooldowns and lockouts are not reported, to avoid revealing the account
+		if err := sendLoginLink(c, &user, deviceHash); err != nil {
+			log.Printf("Login link not issued for user %d: %v", user.CdUser, err)
+		}
+	case err != gorm.ErrRecordNotFound:
+		log.Printf("Error looking up user for passwordless login: %v", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "If this account can log in without a password, a login link has been emailed.",
+		"device_token": deviceToken,
+		"expires_in":   int(appConfig.Passwordless.TTL.Seconds()),
+	})
+}
+
+// VerifyPasswordlessCode - Log in with the code from the login email
+func VerifyPasswordlessCode(c *fiber.Ctx) error {
+	var req PasswordlessCodeRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	invalidCode := func() error {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid or expired code",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.Preload("UserType").Where("email = ?", req.Email).First(&user).Error; err != nil {
+		recordLogin(c, models.LoginEventPasswordless, models.LoginOutcomeFailure, "unknown_account", nil, req.Email)
+		return invalidCode()
+	}
+
+	var link models.LoginLink
+	if err := database.DB.Where("cd_user = ? AND consumed_at IS NULL AND expires_at > ?", user.CdUser, time.Now()).
+		Order("id DESC").
+		First(&link).Error; err != nil {
+		recordLogin(c, models.LoginEventPasswordless, models.LoginOutcomeFailure, "no_pending_link", &user, "")
+		return invalidCode()
+	}
+	if !sameDevice(&link, req.DeviceToken) {
+		recordLogin(c, models.LoginEventPasswordless, models.LoginOutcomeFailure, "device_mismatch", &user, "")
+		return invalidCode()
+	}
+
+	if err := verifyOTP(&user, models.OTPPurposeLogin, req.OTP); err != nil {
+		recordLogin(c, models.LoginEventPasswordless, models.LoginOutcomeFailure, loginFailureReason(err), &user, "")
+		if err == errOTPInvalid || err == errOTPExpired {
+			return invalidCode()
+		}
+		return otpErrorResponse(c, err, "Failed to verify code")
+	}
+
+	return redeemLoginLink(c, &user, &link)
+}
+
+// RedeemLoginLink - Log in with the link from the login email, on the device that asked for it
+func RedeemLoginLink(c *fiber.Ctx) error {
+	var req PasswordlessLinkRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	invalidLink := func() error {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid or expired login link",
+		})
+	}
+
+	var claims loginLinkClaims
+	if err := utils.VerifyPayload(loginLinkTokenPurpose, req.Token, &claims); err != nil || time.Now().Unix() > claims.ExpiresAt {
+		return invalidLink()
+	}
+
+	var user models.User
+	if err := database.DB.Preload("UserType").Where("cd_user = ?", claims.UserID).First(&user).Error; err != nil {
+		return invalidLink()
+	}
+
+	var link models.LoginLink
+	err := database.DB.Where("token_hash = ? AND cd_user = ?", utils.HashToken(req.Token), user.CdUser).First(&link).Error
+	if err != nil || link.ConsumedAt != nil || time.Now().After(link.ExpiresAt) {
+		recordLogin(c, models.LoginEventPasswordless, models.LoginOutcomeFailure, "invalid_link", &user, "")
+		return invalidLink()
+	}
+	if !sameDevice(&link, req.DeviceToken) {
+		recordLogin(c, models.LoginEventPasswordless, models.LoginOutcomeFailure, "device_mismatch", &user, "")
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Open the link on the device where you asked for it, or enter the code from the email there",
+		})
+	}
+
+	return redeemLoginLink(c, &user, &link)
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SetRolePermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+type SetPermissionOverrideRequest struct {
+	Granted bool `json:"granted"`
+}
+
+var (
+	errUnknownPermission = errors.New("unknown permission")
+	errLastRBACManager   = errors.New("no user would hold rbac.manage")
+)
+
+// ListRoles - List roles with their permissions (admin)
+func ListRoles(c *fiber.Ctx) error {
+	var roles []models.Role
+	if err := database.DB.Preload("Permissions").Order("usertype").Find(&roles).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch roles",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"roles": roles,
+	})
+}
+
+// ListPermissions - List every known permission (admin)
+func ListPermissions(c *fiber.Ctx) error {
+	var permissions []models.Permission
+	if err := database.DB.Order("code").Find(&permissions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch permissions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"permissions": permissions,
+	})
+}
+
+// SetRolePermissions - Replace the permission set of a role (admin)
+func SetRolePermissions(c *fiber.Ctx) error {
+	roleID, err := c.ParamsInt("id")
+	if err != nil || roleID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid role ID",
+		})
+	}
+
+	var req SetRolePermissionsRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	var role models.Role
+	if err := database.DB.Where("id = ?", roleID).First(&role).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Role not found",
+		})
+	}
+
+	// Never let the matrix lock everyone out of managing it
+	if role.UserType == models.UserTypeSuperAdmin && !containsString(req.Permissions, models.PermRBACManage) {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Super Admin must keep the " + models.PermRBACManage + " permission",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var permissions []models.Permission
+		if len(req.Permissions) > 0 {
+			if err := tx.Where("code IN ?", req.Permissions).Find(&permissions).Error; err != nil {
+				return err
+			}
+		}
+		if len(permissions) != len(uniqueStrings(req.Permissions)) {
+			return errUnknownPermission
+		}
+
+		return tx.Model(&role).Association("Permissions").Replace(permissions)
+	})
+	if errors.Is(err, errUnknownPermission) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Unknown permission in request",
+		})
+	}
+	if err != nil {
+		log.Printf("Error updating role permissions: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update role permissions",
+		})
+	}
+
+	database.DB.Preload("Permissions").First(&role, role.ID)
+
+	return c.JSON(fiber.Map{
+		"message": "Role permissions updated",
+		"role":    role,
+	})
+}
+
+// GetMyPermissions - List the current user's effective permissions
+func GetMyPermissions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	userType := c.Locals("userType").(int)
+
+	permissions, err := models.EffectivePermissions(database.DB, userID, userType)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to resolve permissions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"permissions": permissions,
+	})
+}
+
+// GetUserPermissions - Show a user's effective permissions and overrides (admin)
+func GetUserPermissions(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", c.Params("id")).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	effective, err := models.EffectivePermissions(database.DB, user.CdUser, user.TyUser)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to resolve permissions",
+		})
+	}
+
+	var overrides []models.UserPermissionOverride
+	if err := database.DB.Preload("Permission").Where("cd_user = ?", user.CdUser).Find(&overrides).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch overrides",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"user_id":     user.CdUser,
+		"userType":    user.TyUser,
+		"permissions": effective,
+		"overrides":   overrides,
+	})
+}
+
+// SetPermissionOverride - Grant or deny one permission to one user (admin)
+func SetPermissionOverride(c *fiber.Ctx) error {
+	var req SetPermissionOverrideRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	user, permission, errResp := loadOverrideTarget(c)
+	if errResp != nil {
+		return errResp()
+	}
+
+	actorID := c.Locals("userID").(uint)
+	if user.CdUser == actorID {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "You cannot override your own permissions",
+		})
+	}
+
+	override := models.UserPermissionOverride{
+		CdUser:       user.CdUser,
+		PermissionID: permission.ID,
+		Granted:      req.Granted,
+		GrantedBy:    &actorID,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "cd_user"}, {Name: "permission_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"granted", "granted_by", "created_at"}),
+		}).Omit("Permission").Create(&override).Error; err != nil {
+			return err
+		}
+		return keepRBACManager(tx, permission)
+	})
+	if errors.Is(err, errLastRBACManager) {
+		return lastRBACManagerResponse(c)
+	}
+	if err != nil {
+		log.Printf("Error saving permission override: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to save override",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":    "Permission override saved",
+		"permission": permission.Code,
+		"granted":    req.Granted,
+	})
+}
+
+// DeletePermissionOverride - Remove a user's override so their role applies again (admin)
+func DeletePermissionOverride(c *fiber.Ctx) error {
+	user, permission, errResp := loadOverrideTarget(c)
+	if errResp != nil {
+		return errResp()
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("cd_user = ? AND permission_id = ?", user.CdUser, permission.ID).
+			Delete(&models.UserPermissionOverride{}).Error; err != nil {
+			return err
+		}
+		return keepRBACManager(tx, permission)
+	})
+	if errors.Is(err, errLastRBACManager) {
+		return lastRBACManagerResponse(c)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete override",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Permission override removed",
+	})
+}
+
+// loadOverrideTarget resolves the :id user and :code permission route parameters
+func loadOverrideTarget(c *fiber.Ctx) (*models.User, *models.Permission, func() error) {
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", c.Params("id")).First(&user).Error; err != nil {
+		return nil, nil, func() error {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "User not found",
+			})
+		}
+	}
+
+	var permission models.Permission
+	if err := database.DB.Where("code = ?", c.Params("code")).First(&permission).Error; err != nil {
+		return nil, nil, func() error {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Permission not found",
+			})
+		}
+	}
+
+	// Admins cannot change the permissions of their peers or superiors
+	if errResp := outrankedResponse(c, &user); errResp != nil {
+		return nil, nil, errResp
+	}
+
+	return &user, &permission, nil
+}
+
+// keepRBACManager fails when a change to permission would leave no active
+// user able to manage roles. The permission row is locked so concurrent
+// overrides are checked one after another.
+func keepRBACManager(tx *gorm.DB, permission *models.Permission) error {
+	if permission.Code != models.PermRBACManage {
+		return nil
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", permission.ID).First(&models.Permission{}).Error; err != nil {
+		return err
+	}
+	holders, err := models.CountPermissionHolders(tx, permission.Code)
+	if err != nil {
+		return err
+	}
+	if holders == 0 {
+		return errLastRBACManager
+	}
+	return nil
+}
+
+func lastRBACManagerResponse(c *fiber.Ctx) error {
+	return c.Status(409).JSON(fiber.Map{
+		"error": "At least one active user must keep the " + models.PermRBACManage + " permission",
+	})
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
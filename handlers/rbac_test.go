@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// seedRBACManage gives the Super Admin role the rbac.manage permission
+func seedRBACManage(t *testing.T) {
+	t.Helper()
+
+	permission := models.Permission{Code: models.PermRBACManage}
+	if err := database.DB.Create(&permission).Error; err != nil {
+		t.Fatalf("create permission: %v", err)
+	}
+	role := models.Role{
+		UserType:    models.UserTypeSuperAdmin,
+		Name:        "Super Admin",
+		Permissions: []models.Permission{permission},
+	}
+	if err := database.DB.Create(&role).Error; err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+}
+
+func newRBACTestApp(actor *models.User) *fiber.App {
+	app := fiber.New()
+	app.Put("/rbac/users/:id/permissions/:code", asUser(actor), SetPermissionOverride)
+	app.Delete("/rbac/users/:id/permissions/:code", asUser(actor), DeletePermissionOverride)
+	return app
+}
+
+func overridePath(user *models.User, code string) string {
+	return fmt.Sprintf("/rbac/users/%d/permissions/%s", user.CdUser, code)
+}
+
+func TestPermissionOverrideRespectsRank(t *testing.T) {
+	setupTestDB(t)
+	seedRBACManage(t)
+	superAdmin := createTestUser(t, "root@vcm.test", models.UserTypeSuperAdmin)
+	admin := createTestUser(t, "admin@vcm.test", models.UserTypeAdmin)
+	otherAdmin := createTestUser(t, "admin2@vcm.test", models.UserTypeAdmin)
+
+	app := newRBACTestApp(admin)
+	for _, target := range []*models.User{superAdmin, otherAdmin} {
+		code, body := sendJSON(t, app, fiber.MethodPut, overridePath(target, models.PermRBACManage), fiber.Map{"granted": false})
+		if code != 403 {
+			t.Fatalf("admin overriding %s: status %d, body %v, want 403", target.Email, code, body)
+		}
+		if code, body := sendJSON(t, app, fiber.MethodDelete, overridePath(target, models.PermRBACManage), nil); code != 403 {
+			t.Fatalf("admin removing override of %s: status %d, body %v, want 403", target.Email, code, body)
+		}
+	}
+}
+
+func TestPermissionOverrideKeepsAnRBACManager(t *testing.T) {
+	setupTestDB(t)
+	seedRBACManage(t)
+	actor := createTestUser(t, "root@vcm.test", models.UserTypeSuperAdmin)
+	other := createTestUser(t, "root2@vcm.test", models.UserTypeSuperAdmin)
+	app := newRBACTestApp(actor)
+
+	// With the actor suspended, the other Super Admin is the only active manager left
+	if err := database.DB.Model(actor).Update("user_status", models.UserStatusSuspended).Error; err != nil {
+		t.Fatalf("suspend actor: %v", err)
+	}
+	code, body := sendJSON(t, app, fiber.MethodPut, overridePath(other, models.PermRBACManage), fiber.Map{"granted": false})
+	if code != 409 {
+		t.Fatalf("denying the last manager: status %d, body %v, want 409", code, body)
+	}
+	var overrides int64
+	database.DB.Model(&models.UserPermissionOverride{}).Where("cd_user = ?", other.CdUser).Count(&overrides)
+	if overrides != 0 {
+		t.Fatalf("%d overrides saved, want none", overrides)
+	}
+
+	if err := database.DB.Model(actor).Update("user_status", models.UserStatusActive).Error; err != nil {
+		t.Fatalf("reactivate actor: %v", err)
+	}
+	code, body = sendJSON(t, app, fiber.MethodPut, overridePath(other, models.PermRBACManage), fiber.Map{"granted": false})
+	if code != 200 {
+		t.Fatalf("denying one of two managers: status %d, body %v, want 200", code, body)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"log"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// activeSessions lists a user's live sessions, most recently used first
+func activeSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := database.DB.
+		Where("cd_user = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func sessionResponseItem(s *models.Session, currentID string) fiber.Map {
+	return fiber.Map{
+		"id":           s.ID,
+		"device":       s.Device,
+		"user_agent":   s.UserAgent,
+		"ip_address":   s.IPAddress,
+		"created_at":   s.CreatedAt,
+		"last_seen_at": s.LastSeenAt,
+		"expires_at":   s.ExpiresAt,
+		"current":      s.ID == currentID,
+	}
+}
+
+// ListSessions - List the current user's active logins
+func ListSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	currentID := c.Locals("sessionID").(string)
+
+	sessions, err := activeSessions(userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch sessions",
+		})
+	}
+
+	result := make([]fiber.Map, 0, len(sessions))
+	for i := range sessions {
+		result = append(result, sessionResponseItem(&sessions[i], currentID))
+	}
+
+	return c.JSON(fiber.Map{
+		"sessions": result,
+	})
+}
+
+// RevokeSession - Log out one of the current user's sessions
+func RevokeSession(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var session models.Session
+	if err := database.DB.Where("id = ? AND cd_user = ? AND revoked_at IS NULL", c.Params("id"), userID).
+		First(&session).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Session not found",
+		})
+	}
+
+	if err := revokeSession(database.DB, session.ID); err != nil {
+		log.Printf("Error revoking session: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to revoke session",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Session revoked",
+	})
+}
+
+// RevokeOtherSessions - Log out everywhere except the current session
+func RevokeOtherSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	currentID := c.Locals("sessionID").(string)
+
+	if err := revokeSessionsExcept(database.DB, userID, currentID); err != nil {
+		log.Printf("Error revoking sessions: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to revoke sessions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "All other sessions revoked",
+	})
+}
+
+// ListUserSessions - List a user's active logins (admin)
+func ListUserSessions(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	sessions, err := activeSessions(uint(userID))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch sessions",
+		})
+	}
+
+	result := make([]fiber.Map, 0, len(sessions))
+	for i := range sessions {
+		result = append(result, sessionResponseItem(&sessions[i], ""))
+	}
+
+	return c.JSON(fiber.Map{
+		"sessions": result,
+	})
+}
+
+// RevokeUserSessions - Log a user out of every device (admin)
+func RevokeUserSessions(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", userID).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if err := revokeAllSessions(database.DB, user.CdUser); err != nil {
+		log.Printf("Error revoking sessions: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to revoke sessions",
+		})
+	}
+
+	log.Printf("🔒 User %d revoked all sessions of user %d", c.Locals("userID").(uint), user.CdUser)
+
+	return c.JSON(fiber.Map{
+		"message": "All sessions revoked",
+	})
+}
+
+// truncateString shortens s to at most n characters
+func truncateString(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// tokenPair is what clients receive whenever a session starts or is refreshed
+type tokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int
+}
+
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+// startSession records a new device session for the user and issues its first tokens
+func startSession(c *fiber.Ctx, user *models.User) (*tokenPair, error) {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	device := c.Get("X-Device-Name")
+	if device == "" {
+		device = utils.DescribeUserAgent(userAgent)
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:         uuid.NewString(),
+		CdUser:     user.CdUser,
+		Device:     truncateString(device, 100),
+		UserAgent:  truncateString(userAgent, 512),
+		IPAddress:  c.IP(),
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(utils.RefreshTokenTTL()),
+	}
+
+	var pair *tokenPair
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = issueTokens(tx, user, session.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recordSessionStart(c, user, &session)
+
+	return pair, nil
+}
+
+// issueTokens stores a fresh refresh token in the session's family and signs a matching access token
+func issueTokens(tx *gorm.DB, user *models.User, sessionID string) (*tokenPair, error) {
+	rawToken, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	refresh := models.RefreshToken{
+		CdUser:    user.CdUser,
+		FamilyID:  sessionID,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(utils.RefreshTokenTTL()),
+	}
+	if err := tx.Create(&refresh).Error; err != nil {
+		return nil, err
+	}
+
+	// The session lives as long as its newest refresh token
+	if err := tx.Model(&models.Session{}).Where("id = ?", sessionID).Updates(map[string]interface{}{
+		"last_seen_at": now,
+		"expires_at":   refresh.ExpiresAt,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	accessToken, err := utils.GenerateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: rawToken,
+		ExpiresIn:    int(utils.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// revokeSession ends one session and invalidates every refresh token in its family
+func revokeSession(tx *gorm.DB, sessionID string) error {
+	now := time.Now()
+	if err := tx.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", now).Error
+}
+
+// revokeAllSessions ends every session the user holds
+func revokeAllSessions(tx *gorm.DB, userID uint) error {
+	return revokeSessionsExcept(tx, userID, "")
+}
+
+// revokeSessionsExcept ends every session of the user other than keepID
+func revokeSessionsExcept(tx *gorm.DB, userID uint, keepID string) error {
+	now := time.Now()
+	if err := tx.Model(&models.Session{}).
+		Where("cd_user = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.RefreshToken{}).
+		Where("cd_user = ? AND family_id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", now).Error
+}
+
+// revokeAllCredentials ends every session and also revokes the user's API
+// keys, OAuth access tokens and OAuth consents, so whoever held the account
+// keeps no way back in. Used when the owner recovers a taken-over account.
+func revokeAllCredentials(tx *gorm.DB, userID uint) error {
+	if err := revokeAllSessions(tx, userID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.APIKey{}).
+		Where("cd_user = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.OAuthAccessToken{}).
+		Where("cd_user = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Where("cd_user = ?", userID).Delete(&models.OAuthConsent{}).Error
+}
+
+// RefreshToken - Exchange a refresh token for a new token pair
+func RefreshToken(c *fiber.Ctx) error {
+	var req RefreshRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	var pair *tokenPair
+	var user models.User
+	var sessionID string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var stored models.RefreshToken
+		if err := tx.Where("token_hash = ?", utils.HashToken(req.RefreshToken)).First(&stored).Error; err != nil {
+			return err
+		}
+
+		if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+			return gorm.ErrRecordNotFound
+		}
+
+		// Mark as used only if nobody beat us to it; otherwise this is a replay
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		if err := tx.Where("cd_user = ?", stored.CdUser).First(&user).Error; err != nil {
+			return err
+		}
+		if user.UserStatus != models.UserStatusActive {
+			return gorm.ErrRecordNotFound
+		}
+
+		sessionID = stored.FamilyID
+		var err error
+		pair, err = issueTokens(tx, &user, stored.FamilyID)
+		return err
+	})
+
+	if errors.Is(err, errRefreshTokenReused) {
+		// A rotated token came back: assume theft and end the whole session
+		var stored models.RefreshToken
+		if err := database.DB.Where("token_hash = ?", utils.HashToken(req.RefreshToken)).First(&stored).Error; err == nil {
+			log.Printf("⚠️  Refresh token reuse detected for user %d, revoking session %s", stored.CdUser, stored.FamilyID)
+			entry := newLoginEvent(c, models.LoginEventRefresh, models.LoginOutcomeFailure, "token_reused", &stored.CdUser, "")
+			entry.SessionID = stored.FamilyID
+			saveLoginEvent(&entry)
+			if err := revokeSession(database.DB, stored.FamilyID); err != nil {
+				log.Printf("Error revoking session: %v", err)
+			}
+		}
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Invalid or expired refresh token",
+		})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if user.CdUser != 0 {
+			recordLogin(c, models.LoginEventRefresh, models.LoginOutcomeFailure, inactiveReason(&user), &user, "")
+		} else {
+			recordLogin(c, models.LoginEventRefresh, models.LoginOutcomeFailure, "invalid_token", nil, "")
+		}
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Invalid or expired refresh token",
+		})
+	}
+	if err != nil {
+		log.Printf("Error refreshing token: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to refresh token",
+		})
+	}
+
+	entry := newLoginEvent(c, models.LoginEventRefresh, models.LoginOutcomeSuccess, "", &user.CdUser, user.Email)
+	entry.SessionID = sessionID
+	saveLoginEvent(&entry)
+
+	return c.JSON(fiber.Map{
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
+	})
+}
+
+// Logout - Revoke the current session so its tokens stop working
+func Logout(c *fiber.Ctx) error {
+	sessionID := c.Locals("sessionID").(string)
+
+	if err := revokeSession(database.DB, sessionID); err != nil {
+		log.Printf("Error revoking session: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to log out",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Logged out successfully",
+	})
+}
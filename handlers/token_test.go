@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+)
+
+func TestRevokeAllCredentials(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "patient@vcm.test", models.UserTypePatient)
+	other := createTestUser(t, "other@vcm.test", models.UserTypePatient)
+
+	expires := time.Now().Add(time.Hour)
+	for i, owner := range []*models.User{user, other} {
+		suffix := string(rune('a' + i))
+		records := []interface{}{
+			&models.Session{ID: "session-" + suffix, CdUser: owner.CdUser, LastSeenAt: time.Now(), ExpiresAt: expires},
+			&models.RefreshToken{CdUser: owner.CdUser, FamilyID: "session-" + suffix, TokenHash: "refresh-" + suffix, ExpiresAt: expires},
+			&models.APIKey{CdUser: owner.CdUser, Name: "key", Prefix: "vcm_" + suffix, KeyHash: "key-" + suffix, ExpiresAt: expires},
+			&models.OAuthAccessToken{ClientID: "app", CdUser: owner.CdUser, TokenHash: "access-" + suffix, Scopes: "openid", ExpiresAt: expires},
+			&models.OAuthConsent{CdUser: owner.CdUser, ClientID: "app", Scopes: "openid"},
+		}
+		for _, record := range records {
+			if err := database.DB.Omit("Client").Create(record).Error; err != nil {
+				t.Fatalf("create %T: %v", record, err)
+			}
+		}
+	}
+
+	if err := revokeAllCredentials(database.DB, user.CdUser); err != nil {
+		t.Fatalf("revokeAllCredentials: %v", err)
+	}
+
+	live := func(model interface{}, userID uint) int64 {
+		var count int64
+		query := database.DB.Model(model).Where("cd_user = ?", userID)
+		if _, ok := model.(*models.OAuthConsent); !ok {
+			query = query.Where("revoked_at IS NULL")
+		}
+		if err := query.Count(&count).Error; err != nil {
+			t.Fatalf("count %T: %v", model, err)
+		}
+		return count
+	}
+	for _, model := range []interface{}{
+		&models.Session{}, &models.RefreshToken{}, &models.APIKey{}, &models.OAuthAccessToken{}, &models.OAuthConsent{},
+	} {
+		if n := live(model, user.CdUser); n != 0 {
+			t.Errorf("%T: %d left for the user, want 0", model, n)
+		}
+		if n := live(model, other.CdUser); n != 1 {
+			t.Errorf("%T: %d left for another user, want 1", model, n)
+		}
+	}
+}
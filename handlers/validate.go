@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"vcm-medical-platform/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseBody decodes the request body into req, a pointer to a request struct,
+// and checks its validate tags. It returns the response to send if the body
+// cannot be used: 400 when it is malformed, 422 listing the invalid fields.
+func parseBody(c *fiber.Ctx, req interface{}) func() error {
+	if err := c.BodyParser(req); err != nil {
+		return func() error {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	if errs := validation.Struct(req); len(errs) > 0 {
+		return func() error {
+			return validationErrorResponse(c, errs...)
+		}
+	}
+	return nil
+}
+
+// validationErrorResponse is the uniform response for invalid request fields
+func validationErrorResponse(c *fiber.Ctx, errs ...validation.FieldError) error {
+	return c.Status(422).JSON(fiber.Map{
+		"error":  "Validation failed",
+		"fields": errs,
+	})
+}
@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/notify"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	wechatStatePurpose  = "wechat_state"
+	wechatStateTTL      = 10 * time.Minute
+	wechatSignupPurpose = "wechat_signup"
+	wechatSignupTTL     = 30 * time.Minute
+
+	wechatModeLogin = "login"
+	wechatModeLink  = "link"
+
+	// wechatStateCookie ties a state to the browser that started the flow
+	wechatStateCookie     = "wechat_state"
+	wechatStateCookiePath = "/api/v1/auth/wechat"
+)
+
+var (
+	errWeChatLinkedElsewhere = errors.New("wechat account linked to another user")
+	errWeChatAlreadyLinked   = errors.New("user already has a wechat account linked")
+)
+
+type WeChatCallbackRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+type WeChatRegisterRequest struct {
+	WeChatToken string `json:"wechat_token" validate:"required"`
+	Email       string `json:"email" validate:"required,email"`
+	// AcceptedDocuments must cover every required consent document in force
+	AcceptedDocuments []uint `json:"accepted_documents"`
+}
+
+// wechatState travels through WeChat's redirect. WeChat caps state at 128
+// bytes, hence the terse keys.
+type wechatState struct {
+	Mode      string `json:"m"`
+	UserID    uint   `json:"u,omitempty"`
+	ExpiresAt int64  `json:"e"`
+	Nonce     string `json:"n"`
+}
+
+// wechatSignupClaims carries a verified WeChat profile into registration
+type wechatSignupClaims struct {
+	OpenID    string `json:"oid"`
+	UnionID   string `json:"uid,omitempty"`
+	Nickname  string `json:"nick,omitempty"`
+	AvatarURL string `json:"av,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+func wechatDisabled(c *fiber.Ctx) error {
+	return c.Status(404).JSON(fiber.Map{
+		"error": "WeChat login is not enabled",
+	})
+}
+
+// respondWithWeChatAuthorize signs a state for mode and returns the
+// authorization URL. The state's nonce is also set in a cookie, so a state
+// cannot be finished in another browser (login CSRF).
+func respondWithWeChatAuthorize(c *fiber.Ctx, mode string, userID uint) error {
+	nonce := strings.ReplaceAll(uuid.NewString(), "-", "")[:16]
+	state, err := utils.SignPayload(wechatStatePurpose, wechatState{
+		Mode:      mode,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(wechatStateTTL).Unix(),
+		Nonce:     nonce,
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to start WeChat authorization",
+		})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     wechatStateCookie,
+		Value:    nonce,
+		Path:     wechatStateCookiePath,
+		MaxAge:   int(wechatStateTTL.Seconds()),
+		Secure:   appConfig.IsProduction(),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+
+	return c.JSON(fiber.Map{
+		"authorize_url": utils.WeChatAuthorizeURL(state),
+		"state":         state,
+	})
+}
+
+// sameWeChatBrowser reports whether the request carries the cookie set when state was issued
+func sameWeChatBrowser(c *fiber.Ctx, state *wechatState) bool {
+	nonce := c.Cookies(wechatStateCookie)
+	return nonce != "" && subtle.ConstantTimeCompare([]byte(nonce), []byte(state.Nonce)) == 1
+}
+
+// findWeChatIdentity matches a profile by openid, then by unionid
+func findWeChatIdentity(profile *utils.WeChatProfile) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := database.DB.Where("provider = ? AND subject = ?", models.IdentityProviderWeChat, profile.OpenID).
+		First(&identity).Error
+	if err == nil {
+		return &identity, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) || profile.UnionID == "" {
+		return nil, err
+	}
+
+	err = database.DB.Where("provider = ? AND union_id = ?", models.IdentityProviderWeChat, profile.UnionID).
+		First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// linkWeChat attaches a verified WeChat profile to the user
+func linkWeChat(tx *gorm.DB, userID uint, profile *utils.WeChatProfile) error {
+	var existing models.UserIdentity
+	err := tx.Where("provider = ? AND subject = ?", models.IdentityProviderWeChat, profile.OpenID).First(&existing).Error
+	if err == nil {
+		if existing.CdUser != userID {
+			return errWeChatLinkedElsewhere
+		}
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var count int64
+	if err := tx.Model(&models.UserIdentity{}).
+		Where("cd_user = ? AND provider = ?", userID, models.IdentityProviderWeChat).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errWeChatAlreadyLinked
+	}
+
+	identity := models.UserIdentity{
+		CdUser:      userID,
+		Provider:    models.IdentityProviderWeChat,
+		Subject:     profile.OpenID,
+		UnionID:     profile.UnionID,
+		DisplayName: truncateString(profile.Nickname, 128),
+		AvatarURL:   truncateString(profile.AvatarURL, 512),
+	}
+	if err := tx.Create(&identity).Error; err != nil {
+		return err
+	}
+
+	// An openid from our Official Account can also receive template messages
+	if appConfig.WeChat.AppID == appConfig.Notify.WeChat.AppID {
+		return tx.Model(&models.User{}).Where("cd_user = ?", userID).
+			Update("wechat_openid", profile.OpenID).Error
+	}
+	return nil
+}
+
+// WeChatAuthorize - Start "Log in with WeChat"
+func WeChatAuthorize(c *fiber.Ctx) error {
+	if !appConfig.WeChat.IsConfigured() {
+		return wechatDisabled(c)
+	}
+	return respondWithWeChatAuthorize(c, wechatModeLogin, 0)
+}
+
+// StartWeChatLink - Start linking WeChat to the current account
+func StartWeChatLink(c *fiber.Ctx) error {
+	if !appConfig.WeChat.IsConfigured() {
+		return wechatDisabled(c)
+	}
+	return respondWithWeChatAuthorize(c, wechatModeLink, c.Locals("userID").(uint))
+}
+
+// WeChatCallback - Finish WeChat authorization: log in, link, or offer registration
+func WeChatCallback(c *fiber.Ctx) error {
+	if !appConfig.WeChat.IsConfigured() {
+		return wechatDisabled(c)
+	}
+
+	var req WeChatCallbackRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	var state wechatState
+	if err := utils.VerifyPayload(wechatStatePurpose, req.State, &state); err != nil || time.Now().Unix() > state.ExpiresAt ||
+		!sameWeChatBrowser(c, &state) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid or expired WeChat authorization. Please try again.",
+		})
+	}
+	// A state is good for one callback
+	c.Cookie(&fiber.Cookie{
+		Name:     wechatStateCookie,
+		Path:     wechatStateCookiePath,
+		Expires:  time.Unix(0, 0),
+		Secure:   appConfig.IsProduction(),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+
+	profile, err := utils.ExchangeWeChatCode(c.UserContext(), req.Code)
+	if err != nil {
+		log.Printf("WeChat code exchange failed: %v", err)
+		recordLogin(c, models.LoginEventWeChat, models.LoginOutcomeFailure, "code_exchange_failed", nil, "")
+		return c.Status(502).JSON(fiber.Map{
+			"error": "WeChat authorization failed",
+		})
+	}
+
+	if state.Mode == wechatModeLink {
+		return finishWeChatLink(c, state.UserID, profile)
+	}
+
+	identity, err := findWeChatIdentity(profile)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return offerWeChatSignup(c, profile)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.Where("cd_user = ?", identity.CdUser).First(&user).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Account not found",
+		})
+	}
+	if errResp := inactiveAccountResponse(c, &user); errResp != nil {
+		recordLogin(c, models.LoginEventWeChat, models.LoginOutcomeFailure, inactiveReason(&user), &user, "")
+		return errResp()
+	}
+
+	database.DB.Model(identity).Update("last_login_at", time.Now())
+	recordLogin(c, models.LoginEventWeChat, models.LoginOutcomeSuccess, "", &user, "")
+
+	return finishLogin(c, &user, "Login successful")
+}
+
+func finishWeChatLink(c *fiber.Ctx, userID uint, profile *utils.WeChatProfile) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return linkWeChat(tx, userID, profile)
+	})
+	switch {
+	case errors.Is(err, errWeChatLinkedElsewhere):
+		return c.Status(409).JSON(fiber.Map{
+			"error": "This WeChat account is already linked to another user",
+		})
+	case errors.Is(err, errWeChatAlreadyLinked):
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Unlink your current WeChat account first",
+		})
+	case err != nil:
+		log.Printf("Error linking WeChat: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to link WeChat account",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":  "WeChat account linked",
+		"nickname": profile.Nickname,
+	})
+}
+
+// offerWeChatSignup hands an unknown WeChat user a token to register with
+func offerWeChatSignup(c *fiber.Ctx, profile *utils.WeChatProfile) error {
+	token, err := utils.SignPayload(wechatSignupPurpose, wechatSignupClaims{
+		OpenID:    profile.OpenID,
+		UnionID:   profile.UnionID,
+		Nickname:  profile.Nickname,
+		AvatarURL: profile.AvatarURL,
+		ExpiresAt: time.Now().Add(wechatSignupTTL).Unix(),
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":               "No account is linked to this WeChat account yet",
+		"registration_required": true,
+		"wechat_token":          token,
+		"nickname":              profile.Nickname,
+		"avatar_url":            profile.AvatarURL,
+	})
+}
+
+// WeChatRegister - Create a patient account for a new WeChat user
+func WeChatRegister(c *fiber.Ctx) error {
+	var req WeChatRegisterRequest
+	if errResp := parseBody(c, &req); errResp != nil {
+		return errResp()
+	}
+
+	var claims wechatSignupClaims
+	if err := utils.VerifyPayload(wechatSignupPurpose, req.WeChatToken, &claims); err != nil || time.Now().Unix() > claims.ExpiresAt {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid or expired WeChat token. Please log in with WeChat again.",
+		})
+	}
+
+	// Linking to an existing account needs its password, so never do it by email alone
+	var existingUser models.User
+	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "An account with this email already exists. Log in and link WeChat from your account settings.",
+		})
+	}
+
+	acceptedDocs, errResp := signupConsents(c, req.AcceptedDocuments)
+	if errResp != nil {
+		return errResp()
+	}
+
+	// WeChat users have no password until they set one through password reset
+	randomPassword, _, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to process password",
+		})
+	}
+	hashedPassword, err := utils.HashPassword(randomPassword)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to process password",
+		})
+	}
+
+	user := models.User{
+		Email:      req.Email,
+		Password:   hashedPassword,
+		TyUser:     models.UserTypePatient,
+		UserStatus: models.UserStatusRegistered,
+	}
+	profile := &utils.WeChatProfile{
+		OpenID:    claims.OpenID,
+		UnionID:   claims.UnionID,
+		Nickname:  claims.Nickname,
+		AvatarURL: claims.AvatarURL,
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := recordConsents(tx, c, user.CdUser, acceptedDocs, true); err != nil {
+			return err
+		}
+		return linkWeChat(tx, user.CdUser, profile)
+	})
+	if errors.Is(err, errWeChatLinkedElsewhere) {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "This WeChat account is already linked to another user",
+		})
+	}
+	if err != nil {
+		log.Printf("Error creating WeChat user: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create user",
+		})
+	}
+
+	// Same verification path as email registration
+	otpCode, err := issueOTP(&user, models.OTPPurposeRegistration)
+	if err != nil {
+		log.Printf("Error issuing OTP: %v", err)
+	} else if err := notify.SendOTP(c.UserContext(), &user, models.OTPPurposeRegistration, otpCode); err != nil {
+		log.Printf("Error sending OTP email: %v", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Registration successful. Please check your email for verification code.",
+		"user_id": user.CdUser,
+	})
+}
+
+// UnlinkWeChat - Remove the WeChat account linked to the current user
+func UnlinkWeChat(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var identity models.UserIdentity
+	if err := database.DB.Where("cd_user = ? AND provider = ?", userID, models.IdentityProviderWeChat).
+		First(&identity).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "No WeChat account linked",
+		})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&identity).Error; err != nil {
+			return err
+		}
+
+		// Stop template messages to the unlinked openid
+		if err := tx.Model(&models.User{}).
+			Where("cd_user = ? AND notification_channel = ?", userID, models.NotificationChannelWeChat).
+			Update("notification_channel", "").Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).
+			Where("cd_user = ? AND wechat_openid = ?", userID, identity.Subject).
+			Update("wechat_openid", "").Error
+	})
+	if err != nil {
+		log.Printf("Error unlinking WeChat: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to unlink WeChat account",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "WeChat account unlinked",
+	})
+}
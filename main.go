@@ -5,6 +5,17 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"vcm-medical-platform/config"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/handlers"
+	"vcm-medical-platform/middleware"
+	"vcm-medical-platform/notify"
+	"vcm-medical-platform/privacy"
+	"vcm-medical-platform/routes"
+	"vcm-medical-platform/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -15,8 +26,55 @@ import (
 //go:embed frontend/dist
 var embedDirStatic embed.FS
 
+// shutdownTimeout bounds how long in-flight requests may take to drain
+const shutdownTimeout = 15 * time.Second
+
+// dataRequestInterval is how often due erasures run and expired exports are dropped
+const dataRequestInterval = 15 * time.Minute
+
 func main() {
-	app := fiber.New()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	utils.InitJWT(cfg.JWT)
+	notify.Init(cfg)
+	utils.InitOTP(cfg.AppSecret)
+	utils.InitSigning(cfg.AppSecret)
+	utils.InitEncryption(cfg.AppSecret)
+	utils.InitWeChatOAuth(cfg.WeChat)
+	if err := utils.InitPassword(cfg.Password); err != nil {
+		log.Fatal(err)
+	}
+	if err := utils.InitWebAuthn(cfg.WebAuthn); err != nil {
+		log.Fatal(err)
+	}
+	handlers.Init(cfg)
+
+	// Database
+	if err := database.Connect(cfg.Database); err != nil {
+		log.Fatal(err)
+	}
+	if err := database.Migrate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := database.SeedData(); err != nil {
+		log.Fatal(err)
+	}
+	middleware.InitRateLimit(cfg.RateLimit)
+	if err := database.RefreshSigningKeys(cfg.JWT, cfg.SignedTokenTTL()); err != nil {
+		log.Fatal(err)
+	}
+	go database.RunSigningKeyRotation(cfg.JWT, cfg.SignedTokenTTL(), cfg.JWT.KeyRefreshInterval())
+	go privacy.RunScheduler(database.DB, dataRequestInterval)
+
+	app := fiber.New(fiber.Config{
+		EnableTrustedProxyCheck: len(cfg.TrustedProxies) > 0,
+		TrustedProxies:          cfg.TrustedProxies,
+		ProxyHeader:             proxyHeader(cfg),
+		EnableIPValidation:      true,
+	})
 
 	app.Use(logger.New())
 	app.Use(cors.New())
@@ -30,11 +88,7 @@ func main() {
 	})
 
 	// API routes
-	app.Get("/api/test", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"message": "API is working!",
-		})
-	})
+	routes.Setup(app)
 
 	// Serve static files
 	app.Use("/", filesystem.New(filesystem.Config{
@@ -48,11 +102,32 @@ func main() {
 		return c.SendFile("frontend/dist/index.html")
 	})
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	go func() {
+		log.Printf("Starting server on port %s (%s)", cfg.Port, cfg.Environment)
+		if err := app.Listen(":" + cfg.Port); err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	// Wait for a termination signal, then drain requests and the DB pool
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down server...")
+	if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+		log.Printf("Error during server shutdown: %v", err)
+	}
+	if err := database.Close(); err != nil {
+		log.Printf("Error closing database: %v", err)
 	}
+	log.Println("✅ Server stopped")
+}
 
-	log.Printf("Starting server on port %s", port)
-	log.Fatal(app.Listen(":" + port))
+// proxyHeader reads client IPs from X-Forwarded-For only behind trusted proxies
+func proxyHeader(cfg *config.Config) string {
+	if len(cfg.TrustedProxies) == 0 {
+		return ""
+	}
+	return fiber.HeaderXForwardedFor
 }
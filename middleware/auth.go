@@ -2,12 +2,23 @@ package middleware
 
 import (
 	"strings"
+	"time"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
 	"vcm-medical-platform/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// AuthMiddleware authenticates a user access token, or an API key sent as the
+// bearer token or in X-API-Key, and sets the caller in c.Locals. A user, or
+// the owner of an API key, who has not accepted the current required
+// documents is turned away unless AllowPendingConsent ran first.
 func AuthMiddleware(c *fiber.Ctx) error {
+	if apiKey := c.Get(APIKeyHeader); apiKey != "" {
+		return authenticateAPIKey(c, apiKey)
+	}
+
 	authHeader := c.Get("Authorization")
 	if authHeader == "" {
 		return c.Status(401).JSON(fiber.Map{
@@ -23,6 +34,10 @@ func AuthMiddleware(c *fiber.Ctx) error {
 	}
 
 	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if utils.IsAPIKey(token) {
+		return authenticateAPIKey(c, token)
+	}
+
 	claims, err := utils.ValidateToken(token)
 	if err != nil {
 		return c.Status(401).JSON(fiber.Map{
@@ -30,12 +45,45 @@ func AuthMiddleware(c *fiber.Ctx) error {
 		})
 	}
 
+	// Reject tokens whose session was logged out or revoked
+	if !isSessionActive(claims.SessionID, claims.UserID) {
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Session has been revoked",
+		})
+	}
+
 	// Set user info in context
 	c.Locals("userID", claims.UserID)
 	c.Locals("userEmail", claims.Email)
 	c.Locals("userType", claims.UserType)
+	c.Locals("sessionID", claims.SessionID)
 
-	return c.Next()
+	// Users must accept changed terms before the session is of any use
+	return requireConsent(c, claims.UserID)
+}
+
+// sessionTouchInterval limits how often last_seen_at is written per session
+const sessionTouchInterval = time.Minute
+
+// isSessionActive reports whether the session is live, recording that it was seen
+func isSessionActive(sessionID string, userID uint) bool {
+	if sessionID == "" {
+		return false
+	}
+
+	now := time.Now()
+	var session models.Session
+	err := database.DB.
+		Where("id = ? AND cd_user = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, now).
+		First(&session).Error
+	if err != nil {
+		return false
+	}
+
+	if now.Sub(session.LastSeenAt) > sessionTouchInterval {
+		database.DB.Model(&models.Session{}).Where("id = ?", sessionID).Update("last_seen_at", now)
+	}
+	return true
 }
 
 func RequireUserType(allowedTypes ...int) fiber.Handler {
@@ -53,3 +101,34 @@ func RequireUserType(allowedTypes ...int) fiber.Handler {
 		})
 	}
 }
+
+// RequirePermission allows the request only if the authenticated user holds
+// the permission through their role or a per-user override
+func RequirePermission(code string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		userType := c.Locals("userType").(int)
+
+		allowed, err := models.UserHasPermission(database.DB, userID, userType, code)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to check permissions",
+			})
+		}
+
+		if !allowed {
+			return c.Status(403).JSON(fiber.Map{
+				"error": "Insufficient permissions",
+			})
+		}
+
+		// An API key only carries the permissions it was scoped to
+		if scopes, ok := c.Locals("apiKeyScopes").([]string); ok && !hasScope(scopes, code) {
+			return c.Status(403).JSON(fiber.Map{
+				"error": "API key is not scoped for this action",
+			})
+		}
+
+		return c.Next()
+	}
+}
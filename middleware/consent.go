@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"log"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+	"vcm-medical-platform/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AllowPendingConsent lets a user who still has to accept changed documents
+// through AuthMiddleware. It goes before AuthMiddleware on the routes needed
+// to read and accept them, log out, or leave the platform instead.
+func AllowPendingConsent(c *fiber.Ctx) error {
+	c.Locals("allowPendingConsent", true)
+	return c.Next()
+}
+
+// requireConsent continues only if the user has accepted the current version
+// of every required document for their country
+func requireConsent(c *fiber.Ctx, userID uint) error {
+	if allowed, _ := c.Locals("allowPendingConsent").(bool); allowed {
+		return c.Next()
+	}
+
+	var user models.User
+	if err := database.DB.Select("cd_user", "cd_country").Where("cd_user = ?", userID).First(&user).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	lang := utils.PrimaryLanguage(c.Query("lang"), c.Get(fiber.HeaderAcceptLanguage))
+	pending, err := models.PendingConsents(database.DB, &user, lang)
+	if err != nil {
+		log.Printf("Error checking consents for user %d: %v", userID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to check consents",
+		})
+	}
+	if len(pending) == 0 {
+		return c.Next()
+	}
+
+	// The full text is served by the consent endpoints
+	for i := range pending {
+		pending[i].Content = ""
+	}
+	return c.Status(403).JSON(fiber.Map{
+		"error":            "Please review and accept the updated documents to continue",
+		"consent_required": true,
+		"documents":        pending,
+	})
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey lets a partner's systems call the API as the owning user. Only the
+// hash is stored; the key itself is shown once when it is created.
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser     uint       `gorm:"not null;index" json:"cd_user"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:16;not null" json:"prefix"`
+	KeyHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Scopes     string     `gorm:"size:1024;not null;default:''" json:"-"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"column:last_used_ip;size:45;not null;default:''" json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// ScopeList returns the permission codes the key may exercise
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of consent document
+const (
+	ConsentTerms       = "terms"
+	ConsentPrivacy     = "privacy"
+	ConsentMedicalData = "medical_data"
+	ConsentMarketing   = "marketing"
+	ConsentResearch    = "research"
+)
+
+// ConsentKinds lists every kind of consent document
+var ConsentKinds = []string{ConsentTerms, ConsentPrivacy, ConsentMedicalData, ConsentMarketing, ConsentResearch}
+
+// IsValidConsentKind reports whether kind is a known consent document kind
+func IsValidConsentKind(kind string) bool {
+	for _, k := range ConsentKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRequiredConsent reports whether the platform cannot be used without
+// accepting the kind of document. Other consents can be withdrawn at any time.
+func IsRequiredConsent(kind string) bool {
+	return kind == ConsentTerms || kind == ConsentPrivacy || kind == ConsentMedicalData
+}
+
+// ConsentDocument is one language version of a legal document. Publishing a
+// higher version of a required kind asks every affected user to accept again.
+type ConsentDocument struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Kind        string    `gorm:"size:32;not null" json:"kind"`
+	CdCountry   int       `gorm:"not null;default:0" json:"cd_country"`
+	Language    string    `gorm:"size:8;not null" json:"language"`
+	Version     int       `gorm:"not null" json:"version"`
+	Title       string    `gorm:"size:255;not null" json:"title"`
+	Content     string    `gorm:"type:text;not null;default:''" json:"content,omitempty"`
+	URL         string    `gorm:"size:512;not null;default:''" json:"url,omitempty"`
+	PublishedAt time.Time `gorm:"not null" json:"published_at"`
+	CreatedBy   *uint     `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (ConsentDocument) TableName() string {
+	return "consent_documents"
+}
+
+// Required reports whether the document must be accepted to use the platform
+func (d *ConsentDocument) Required() bool {
+	return IsRequiredConsent(d.Kind)
+}
+
+// UserConsent records a user accepting or withdrawing a document version,
+// with where the decision was made
+type UserConsent struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser     uint      `gorm:"not null;index" json:"cd_user"`
+	DocumentID uint      `gorm:"not null" json:"document_id"`
+	Kind       string    `gorm:"size:32;not null" json:"kind"`
+	CdCountry  int       `gorm:"not null;default:0" json:"cd_country"`
+	Version    int       `gorm:"not null" json:"version"`
+	Granted    bool      `gorm:"not null" json:"granted"`
+	IPAddress  string    `gorm:"size:45;not null;default:''" json:"ip_address"`
+	UserAgent  string    `gorm:"size:512;not null;default:''" json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (UserConsent) TableName() string {
+	return "user_consents"
+}
+
+// Covers reports whether this decision is a grant of the document's version
+func (uc *UserConsent) Covers(doc *ConsentDocument) bool {
+	return uc.Granted && uc.Kind == doc.Kind && uc.CdCountry == doc.CdCountry && uc.Version >= doc.Version
+}
+
+// DefaultConsentLanguage is shown when a document has no version in the
+// reader's language
+const DefaultConsentLanguage = "en"
+
+// CurrentConsentDocuments returns the document in force for each kind in a
+// country, in the language closest to lang. A country without its own
+// published version of a kind falls back to the default (cd_country 0).
+func CurrentConsentDocuments(db *gorm.DB, country int, lang string) ([]ConsentDocument, error) {
+	var published []ConsentDocument
+	if err := db.Where("cd_country IN ? AND published_at <= ?", []int{0, country}, time.Now()).
+		Order("version DESC, id").
+		Find(&published).Error; err != nil {
+		return nil, err
+	}
+
+	var current []ConsentDocument
+	for _, kind := range ConsentKinds {
+		docCountry := 0
+		for _, doc := range published {
+			if doc.Kind == kind && doc.CdCountry == country {
+				docCountry = country
+				break
+			}
+		}
+
+		// Newest version first, so the candidates are the leading rows
+		var candidates []ConsentDocument
+		for _, doc := range published {
+			if doc.Kind != kind || doc.CdCountry != docCountry {
+				continue
+			}
+			if len(candidates) > 0 && doc.Version != candidates[0].Version {
+				break
+			}
+			candidates = append(candidates, doc)
+		}
+		if len(candidates) > 0 {
+			current = append(current, pickConsentLanguage(candidates, lang))
+		}
+	}
+	return current, nil
+}
+
+// pickConsentLanguage prefers lang, then the default language, then any translation
+func pickConsentLanguage(docs []ConsentDocument, lang string) ConsentDocument {
+	for _, want := range []string{lang, DefaultConsentLanguage} {
+		for _, doc := range docs {
+			if doc.Language == want {
+				return doc
+			}
+		}
+	}
+	return docs[0]
+}
+
+// IsSameVersion reports whether other is a translation of the same document version
+func (d *ConsentDocument) IsSameVersion(other *ConsentDocument) bool {
+	return d.Kind == other.Kind && d.CdCountry == other.CdCountry && d.Version == other.Version
+}
+
+// LatestUserConsents returns the user's most recent decision for each kind
+func LatestUserConsents(db *gorm.DB, userID uint) (map[string]UserConsent, error) {
+	var rows []UserConsent
+	if err := db.Raw(`SELECT DISTINCT ON (kind) * FROM user_consents
+		WHERE cd_user = ? ORDER BY kind, id DESC`, userID).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]UserConsent, len(rows))
+	for _, row := range rows {
+		latest[row.Kind] = row
+	}
+	return latest, nil
+}
+
+// PendingConsents returns the required documents the user has not accepted in
+// their current version, for the user's country
+func PendingConsents(db *gorm.DB, user *User, lang string) ([]ConsentDocument, error) {
+	current, err := CurrentConsentDocuments(db, user.CdCountry, lang)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := LatestUserConsents(db, user.CdUser)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []ConsentDocument{}
+	for i := range current {
+		doc := &current[i]
+		if !doc.Required() {
+			continue
+		}
+		if decision, ok := latest[doc.Kind]; !ok || !decision.Covers(doc) {
+			pending = append(pending, *doc)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Contact details a user can change after verifying the new value
+const (
+	ContactKindEmail = "email"
+	ContactKindPhone = "phone"
+)
+
+// States of a contact change
+const (
+	ContactChangePending   = "pending"
+	ContactChangeCompleted = "completed"
+	ContactChangeCancelled = "cancelled"
+	ContactChangeUndone    = "undone"
+)
+
+// ContactChange is a request to replace the user's email or phone number.
+// The old value stays in effect until the code sent to the new one is
+// confirmed; afterwards the previous address holds a link to undo it.
+type ContactChange struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser        uint       `gorm:"not null;index" json:"cd_user"`
+	Kind          string     `gorm:"size:16;not null" json:"kind"`
+	OldValue      string     `gorm:"size:320;not null;default:''" json:"old_value"`
+	NewValue      string     `gorm:"size:320;not null" json:"new_value"`
+	Status        string     `gorm:"size:16;not null;default:'pending'" json:"status"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	CompletedAt   *time.Time `json:"completed_at"`
+	UndoTokenHash *string    `gorm:"size:64;uniqueIndex" json:"-"`
+	UndoExpiresAt *time.Time `json:"undo_expires_at"`
+	UndoneAt      *time.Time `json:"undone_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (ContactChange) TableName() string {
+	return "contact_changes"
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Kinds of personal data request
+const (
+	DataRequestExport  = "export"
+	DataRequestErasure = "erasure"
+)
+
+// States of a personal data request
+const (
+	DataRequestPending   = "pending"
+	DataRequestCompleted = "completed"
+	DataRequestFailed    = "failed"
+	DataRequestCancelled = "cancelled"
+	DataRequestExpired   = "expired"
+)
+
+// DataRequest is a data subject request: an export of everything we hold
+// about a user, or the erasure of their personal data. Rows are kept after
+// completion as the record that the request was honoured.
+type DataRequest struct {
+	ID     uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser uint   `gorm:"not null;index" json:"cd_user"`
+	Kind   string `gorm:"size:16;not null" json:"kind"`
+	Status string `gorm:"size:16;not null;default:'pending'" json:"status"`
+	// RequestedBy is the admin who filed the request; nil means the user did
+	RequestedBy  *uint      `json:"requested_by"`
+	Reason       string     `gorm:"size:255;not null;default:''" json:"reason"`
+	ScheduledFor time.Time  `gorm:"not null" json:"scheduled_for"`
+	CompletedAt  *time.Time `json:"completed_at"`
+	Archive      []byte     `json:"-"`
+	ArchiveSize  int        `gorm:"not null;default:0" json:"archive_size"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	Error        string     `gorm:"size:512;not null;default:''" json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (DataRequest) TableName() string {
+	return "data_requests"
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// External identity providers a user can log in with
+const (
+	IdentityProviderWeChat = "wechat"
+)
+
+// UserIdentity links a verified external account to a user. Subject is the
+// provider's user ID (the WeChat openid); UnionID ties together openids the
+// same person has across our WeChat apps.
+type UserIdentity struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser      uint       `gorm:"not null;index" json:"cd_user"`
+	Provider    string     `gorm:"size:16;not null" json:"provider"`
+	Subject     string     `gorm:"size:128;not null" json:"-"`
+	UnionID     string     `gorm:"size:128;not null;default:''" json:"-"`
+	DisplayName string     `gorm:"size:128;not null;default:''" json:"display_name"`
+	AvatarURL   string     `gorm:"size:512;not null;default:''" json:"avatar_url"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Invitation lets an admin onboard a user type that cannot self-register
+type Invitation struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Email       string     `gorm:"size:320;not null;index" json:"email"`
+	TyUser      int        `gorm:"not null" json:"ty_user"`
+	SubtypeUser int        `gorm:"not null;default:0" json:"subtype_user"`
+	TokenHash   string     `gorm:"size:64;not null" json:"-"`
+	InvitedBy   uint       `gorm:"not null" json:"invited_by"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	AcceptedBy  *uint      `json:"accepted_by"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (Invitation) TableName() string {
+	return "invitations"
+}
+
+// Status summarises where the invitation is in its lifecycle
+func (i *Invitation) Status() string {
+	switch {
+	case i.AcceptedAt != nil:
+		return "accepted"
+	case i.RevokedAt != nil:
+		return "revoked"
+	case time.Now().After(i.ExpiresAt):
+		return "expired"
+	default:
+		return "pending"
+	}
+}
+
+// SelfRegistrationUserTypes are the user types that may sign up without an invitation
+var SelfRegistrationUserTypes = []int{
+	UserTypePatient,
+	UserTypeAgent,
+	UserTypeSalesChannel,
+	UserTypeInfluencer,
+	UserTypeDistributor,
+}
+
+// CanSelfRegister reports whether userType may sign up without an invitation
+func CanSelfRegister(userType int) bool {
+	for _, t := range SelfRegistrationUserTypes {
+		if t == userType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Authentication steps recorded in the login history
+const (
+	LoginEventPassword       = "password"
+	LoginEventOTP            = "otp"
+	LoginEventMFA            = "mfa"
+	LoginEventWeChat         = "wechat"
+	LoginEventPasswordless   = "passwordless"
+	LoginEventPasskey        = "passkey"
+	LoginEventRefresh        = "refresh"
+	LoginEventSessionStarted = "session_started"
+)
+
+// Outcomes of an authentication step
+const (
+	LoginOutcomeSuccess = "success"
+	LoginOutcomeFailure = "failure"
+)
+
+// LoginEvent records one authentication attempt with where it came from.
+// Successful sessions carry whether the device or country was new to the user.
+type LoginEvent struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser     *uint     `gorm:"index" json:"cd_user"`
+	Email      string    `gorm:"size:320;not null;default:''" json:"email"`
+	Event      string    `gorm:"size:24;not null" json:"event"`
+	Outcome    string    `gorm:"size:16;not null" json:"outcome"`
+	Reason     string    `gorm:"size:64;not null;default:''" json:"reason,omitempty"`
+	IPAddress  string    `gorm:"size:45;not null;default:''" json:"ip_address"`
+	UserAgent  string    `gorm:"size:512;not null;default:''" json:"user_agent"`
+	Device     string    `gorm:"size:100;not null;default:''" json:"device"`
+	Country    string    `gorm:"size:2;not null;default:''" json:"country,omitempty"`
+	SessionID  string    `gorm:"size:36;not null;default:''" json:"session_id,omitempty"`
+	NewDevice  bool      `gorm:"not null;default:false" json:"new_device"`
+	NewCountry bool      `gorm:"not null;default:false" json:"new_country"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (LoginEvent) TableName() string {
+	return "login_events"
+}
+
+// IsSuspicious reports whether the event was flagged for the user's attention
+func (e *LoginEvent) IsSuspicious() bool {
+	return e.NewDevice || e.NewCountry
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// LoginLink is a passwordless login request. It can be redeemed once, by the
+// emailed link or code, and only from the device that asked for it.
+type LoginLink struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser     uint       `gorm:"not null;index" json:"cd_user"`
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	DeviceHash string     `gorm:"size:64;not null" json:"-"`
+	IPAddress  string     `gorm:"size:45;not null;default:''" json:"ip_address"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (LoginLink) TableName() string {
+	return "login_links"
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// UserTOTP is a user's authenticator app enrolment. The secret is encrypted
+// at rest and only trusted once ConfirmedAt is set.
+type UserTOTP struct {
+	CdUser       uint       `gorm:"primaryKey" json:"cd_user"`
+	SecretEnc    string     `gorm:"size:255;not null" json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (UserTOTP) TableName() string {
+	return "user_totp"
+}
+
+// MFARecoveryCode is a single-use backup code, stored only as a hash
+type MFARecoveryCode struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser    uint       `gorm:"not null;index" json:"cd_user"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+// OTPPurposeMFA throttles second-factor guesses with the OTP lockout machinery
+const OTPPurposeMFA OTPPurpose = "mfa"
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Notification channels a user can receive one-time codes on
+const (
+	NotificationChannelEmail  = "email"
+	NotificationChannelSMS    = "sms"
+	NotificationChannelWeChat = "wechat"
+)
+
+// Delivery states of a notification
+const (
+	DeliveryStatusPending = "pending"
+	DeliveryStatusSent    = "sent"
+	DeliveryStatusFailed  = "failed"
+)
+
+// CountryChina is the cd_country of mainland China, where SMS is preferred over email
+const CountryChina = 86
+
+// IsValidNotificationChannel reports whether channel is a known channel
+func IsValidNotificationChannel(channel string) bool {
+	switch channel {
+	case NotificationChannelEmail, NotificationChannelSMS, NotificationChannelWeChat:
+		return true
+	}
+	return false
+}
+
+// NotificationDelivery records one attempt to deliver a message. The
+// recipient is stored masked and message content is never stored.
+type NotificationDelivery struct {
+	ID                uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser            *uint      `gorm:"index" json:"cd_user"`
+	Channel           string     `gorm:"size:16;not null" json:"channel"`
+	Recipient         string     `gorm:"size:320;not null" json:"recipient"`
+	Template          string     `gorm:"size:32;not null" json:"template"`
+	Status            string     `gorm:"size:16;not null" json:"status"`
+	ProviderMessageID string     `gorm:"size:128;not null;default:''" json:"provider_message_id"`
+	Error             string     `gorm:"size:512;not null;default:''" json:"error,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	SentAt            *time.Time `json:"sent_at"`
+}
+
+func (NotificationDelivery) TableName() string {
+	return "notification_deliveries"
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Scopes partner clients may request from the OpenID Connect provider
+const (
+	OAuthScopeOpenID  = "openid"
+	OAuthScopeProfile = "profile"
+	OAuthScopeEmail   = "email"
+	OAuthScopePhone   = "phone"
+)
+
+// SupportedOAuthScopes lists every scope in the order shown to users
+var SupportedOAuthScopes = []string{OAuthScopeOpenID, OAuthScopeProfile, OAuthScopeEmail, OAuthScopePhone}
+
+// OAuthScopeDescriptions explains each scope on the consent screen
+var OAuthScopeDescriptions = map[string]string{
+	OAuthScopeOpenID:  "Sign you in with your VCM account and see your account type",
+	OAuthScopeProfile: "See your name, gender and date of birth",
+	OAuthScopeEmail:   "See your email address",
+	OAuthScopePhone:   "See your phone number",
+}
+
+// IsValidOAuthScope reports whether scope is one the provider supports
+func IsValidOAuthScope(scope string) bool {
+	_, ok := OAuthScopeDescriptions[scope]
+	return ok
+}
+
+// OAuthClient is a partner portal registered to log users in through
+// OpenID Connect. Public clients have no secret and rely on PKCE alone.
+type OAuthClient struct {
+	ClientID     string    `gorm:"primaryKey;size:64" json:"client_id"`
+	SecretHash   string    `gorm:"size:64;not null;default:''" json:"-"`
+	Name         string    `gorm:"size:128;not null" json:"name"`
+	LogoURL      string    `gorm:"size:512;not null;default:''" json:"logo_url"`
+	RedirectURIs string    `gorm:"column:redirect_uris;not null" json:"-"`
+	Scopes       string    `gorm:"size:255;not null" json:"-"`
+	CreatedBy    *uint     `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// IsConfidential reports whether the client must authenticate with a secret
+func (c *OAuthClient) IsConfidential() bool {
+	return c.SecretHash != ""
+}
+
+// AllowsRedirectURI reports whether uri exactly matches a registered redirect URI
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	for _, registered := range strings.Fields(c.RedirectURIs) {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthConsent remembers the scopes a user has agreed to share with a client
+type OAuthConsent struct {
+	CdUser    uint      `gorm:"primaryKey" json:"cd_user"`
+	ClientID  string    `gorm:"primaryKey;size:64" json:"client_id"`
+	Scopes    string    `gorm:"size:255;not null" json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Client OAuthClient `gorm:"foreignKey:ClientID;references:ClientID" json:"-"`
+}
+
+func (OAuthConsent) TableName() string {
+	return "oauth_consents"
+}
+
+// OAuthAuthorizationCode is a single-use code bound to the PKCE challenge and
+// redirect URI of the request that produced it
+type OAuthAuthorizationCode struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement"`
+	CodeHash      string    `gorm:"size:64;uniqueIndex;not null"`
+	ClientID      string    `gorm:"size:64;not null"`
+	CdUser        uint      `gorm:"not null"`
+	RedirectURI   string    `gorm:"size:512;not null"`
+	Scopes        string    `gorm:"size:255;not null"`
+	Nonce         string    `gorm:"size:255;not null;default:''"`
+	CodeChallenge string    `gorm:"size:128;not null"`
+	AuthTime      time.Time `gorm:"not null"`
+	ExpiresAt     time.Time `gorm:"not null"`
+	UsedAt        *time.Time
+	CreatedAt     time.Time
+}
+
+func (OAuthAuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
+
+// OAuthAccessToken is an opaque token a client presents to the userinfo endpoint
+type OAuthAccessToken struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	TokenHash string `gorm:"size:64;uniqueIndex;not null"`
+	ClientID  string `gorm:"size:64;not null"`
+	CdUser    uint   `gorm:"not null"`
+	// CodeID lets a replayed authorization code revoke the tokens it produced
+	CodeID    *uint
+	Scopes    string    `gorm:"size:255;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+func (OAuthAccessToken) TableName() string {
+	return "oauth_access_tokens"
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// OTPPurpose scopes a one-time code to the flow that issued it
+type OTPPurpose string
+
+const (
+	OTPPurposeRegistration OTPPurpose = "registration"
+	OTPPurposeLogin        OTPPurpose = "login"
+	OTPPurposeReset        OTPPurpose = "reset"
+	OTPPurposeEmailChange  OTPPurpose = "email_change"
+	OTPPurposePhoneChange  OTPPurpose = "phone_change"
+)
+
+// OTPChallenge is a single issued code, stored only as a hash
+type OTPChallenge struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser     uint       `gorm:"not null;index" json:"cd_user"`
+	Purpose    OTPPurpose `gorm:"size:24;not null" json:"purpose"`
+	CodeHash   string     `gorm:"size:64;not null" json:"-"`
+	Attempts   int        `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (OTPChallenge) TableName() string {
+	return "otp_challenge"
+}
+
+// OTPThrottle tracks sends and failed guesses per user and purpose
+type OTPThrottle struct {
+	CdUser         uint       `gorm:"primaryKey" json:"cd_user"`
+	Purpose        OTPPurpose `gorm:"primaryKey;size:24" json:"purpose"`
+	FailedAttempts int        `gorm:"not null;default:0" json:"failed_attempts"`
+	LockoutCount   int        `gorm:"not null;default:0" json:"lockout_count"`
+	LockedUntil    *time.Time `json:"locked_until"`
+	LastSentAt     *time.Time `json:"last_sent_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (OTPThrottle) TableName() string {
+	return "otp_throttle"
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Permission codes checked by middleware.RequirePermission
+const (
+	PermUsersRead           = "users.read"
+	PermUsersStatusManage   = "users.status.manage"
+	PermUsersSessionsManage = "users.sessions.manage"
+	PermInvitationsManage   = "invitations.manage"
+	PermRBACManage          = "rbac.manage"
+	PermOAuthClientsManage  = "oauth.clients.manage"
+	PermAPIKeysManage       = "api_keys.manage"
+	PermUsersAPIKeysManage  = "users.api_keys.manage"
+	PermUsersDataExport     = "users.data.export"
+	PermUsersDataErase      = "users.data.erase"
+	PermConsentsManage      = "consents.manage"
+)
+
+// Role groups permissions; every user type has exactly one role
+type Role struct {
+	ID          uint         `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserType    int          `gorm:"column:usertype;uniqueIndex;not null" json:"usertype"`
+	Name        string       `gorm:"size:64;not null" json:"name"`
+	Permissions []Permission `gorm:"many2many:role_permissions;joinForeignKey:RoleID;joinReferences:PermissionID" json:"permissions,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+type Permission struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code        string `gorm:"size:64;uniqueIndex;not null" json:"code"`
+	Description string `gorm:"size:255;not null;default:''" json:"description"`
+}
+
+func (Permission) TableName() string {
+	return "permissions"
+}
+
+// UserPermissionOverride grants or denies a single permission to one user,
+// taking precedence over their role
+type UserPermissionOverride struct {
+	CdUser       uint       `gorm:"primaryKey" json:"cd_user"`
+	PermissionID uint       `gorm:"primaryKey" json:"permission_id"`
+	Granted      bool       `gorm:"not null" json:"granted"`
+	GrantedBy    *uint      `json:"granted_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Permission   Permission `gorm:"foreignKey:PermissionID" json:"permission"`
+}
+
+func (UserPermissionOverride) TableName() string {
+	return "user_permission_overrides"
+}
+
+// UserHasPermission resolves a permission for a user: an override wins,
+// otherwise the role for their user type decides
+func UserHasPermission(db *gorm.DB, userID uint, userType int, code string) (bool, error) {
+	var allowed bool
+	err := db.Raw(`
+		SELECT COALESCE(
+			(SELECT o.granted FROM user_permission_overrides o
+				JOIN permissions p ON p.id = o.permission_id
+				WHERE o.cd_user = ? AND p.code = ?),
+			EXISTS (SELECT 1 FROM role_permissions rp
+				JOIN roles r ON r.id = rp.role_id
+				JOIN permissions p ON p.id = rp.permission_id
+				WHERE r.usertype = ? AND p.code = ?)
+		)`, userID, code, userType, code).Scan(&allowed).Error
+
+	return allowed, err
+}
+
+// EffectivePermissions lists every permission code the user currently holds
+func EffectivePermissions(db *gorm.DB, userID uint, userType int) ([]string, error) {
+	var codes []string
+	err := db.Raw(`
+		SELECT p.code FROM permissions p
+		WHERE COALESCE(
+			(SELECT o.granted FROM user_permission_overrides o
+				WHERE o.cd_user = ? AND o.permission_id = p.id),
+			EXISTS (SELECT 1 FROM role_permissions rp
+				JOIN roles r ON r.id = rp.role_id
+				WHERE r.usertype = ? AND rp.permission_id = p.id)
+		)
+		ORDER BY p.code`, userID, userType).Scan(&codes).Error
+
+	return codes, err
+}
+
+// CountPermissionHolders counts active users who currently hold the permission
+func CountPermissionHolders(db *gorm.DB, code string) (int64, error) {
+	var count int64
+	err := db.Raw(`
+		SELECT COUNT(*) FROM users u
+		WHERE u.deleted_at IS NULL AND u.user_status = ?
+		AND COALESCE(
+			(SELECT o.granted FROM user_permission_overrides o
+				JOIN permissions p ON p.id = o.permission_id
+				WHERE o.cd_user = u.cd_user AND p.code = ?),
+			EXISTS (SELECT 1 FROM role_permissions rp
+				JOIN roles r ON r.id = rp.role_id
+				JOIN permissions p ON p.id = rp.permission_id
+				WHERE r.usertype = u.ty_user AND p.code = ?)
+		)`, UserStatusActive, code, code).Scan(&count).Error
+
+	return count, err
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Session is one login on one device. Its ID doubles as the refresh token
+// family and the sid claim of every access token issued for it.
+type Session struct {
+	ID         string    `gorm:"primaryKey;size:36" json:"id"`
+	CdUser     uint      `gorm:"not null;index" json:"cd_user"`
+	Device     string    `gorm:"size:100;not null" json:"device"`
+	UserAgent  string    `gorm:"size:512;not null" json:"user_agent"`
+	IPAddress  string    `gorm:"size:45;not null" json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `gorm:"not null" json:"last_seen_at"`
+	// ExpiresAt follows the newest refresh token; the session ends with it
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
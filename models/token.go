@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// RefreshToken is an opaque, single-use credential exchanged for a new access
+// token. Every rotation stays in the same family so that replaying a used
+// token can revoke the whole chain.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser    uint       `gorm:"not null;index" json:"cd_user"`
+	FamilyID  string     `gorm:"size:36;not null;index" json:"family_id"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// SigningKey is a JWT signing key. The private key is encrypted at rest; the
+// public key is published through the JWKS endpoint until RetiresAt.
+type SigningKey struct {
+	Kid           string    `gorm:"primaryKey;size:36" json:"kid"`
+	Algorithm     string    `gorm:"size:16;not null" json:"algorithm"`
+	PrivateKeyEnc string    `gorm:"not null" json:"-"`
+	PublicKey     string    `gorm:"size:64;not null" json:"public_key"`
+	NotBefore     time.Time `gorm:"not null" json:"not_before"`
+	ExpiresAt     time.Time `gorm:"not null" json:"expires_at"`
+	RetiresAt     time.Time `gorm:"not null" json:"retires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (SigningKey) TableName() string {
+	return "jwt_signing_keys"
+}
@@ -1,10 +1,24 @@
 package models
 
 import (
+	"strings"
 	"time"
 	"gorm.io/gorm"
 )
 
+// User types, matching the rows seeded into the usertype table
+const (
+	UserTypePatient      = 0
+	UserTypeAgent        = 1
+	UserTypeSalesChannel = 2
+	UserTypeInfluencer   = 3
+	UserTypeDistributor  = 4
+	UserTypeDoctor       = 5
+	UserTypeOperator     = 10
+	UserTypeAdmin        = 11
+	UserTypeSuperAdmin   = 12
+)
+
 type UserType struct {
 	UserType     int    `gorm:"primaryKey" json:"usertype"`
 	UserTypeName string `gorm:"size:64;not null" json:"usertype_name"`
@@ -16,11 +30,7 @@ type User struct {
 	TyUser       int       `gorm:"not null" json:"ty_user"`
 	SubtypeUser  int       `gorm:"not null;default:0" json:"subtype_user"`
 	Email        string    `gorm:"size:320;uniqueIndex;not null" json:"email"`
-	Password     string    `gorm:"size:60;not null" json:"-"`
-	
-	// OTP Info
-	OtpCode      string    `gorm:"size:6;not null;default:''" json:"-"`
-	OtpCreatedAt time.Time `gorm:"default:'1970-01-01 00:00:01'" json:"-"`
+	Password     string    `gorm:"size:255;not null" json:"-"`
 	
 	// Personal Information
 	FirstName    string    `gorm:"size:64;not null;default:''" json:"first_name"`
@@ -29,6 +39,13 @@ type User struct {
 	PhoneNumber  string    `gorm:"size:30;not null;default:''" json:"phone_number"`
 	DateOfBirth  time.Time `gorm:"not null;default:'1900-01-01'" json:"date_of_birth"`
 	WechatId     string    `gorm:"size:64;not null;default:''" json:"wechat_id"`
+	// WechatOpenID identifies the user to our WeChat Official Account
+	WechatOpenID string `gorm:"column:wechat_openid;size:64;not null;default:''" json:"-"`
+	// NotificationChannel is the preferred channel for codes; empty means automatic
+	NotificationChannel string `gorm:"size:16;not null;default:''" json:"notification_channel"`
+	// PhoneVerifiedAt is set when the number was confirmed with a code; only
+	// then may codes be sent to it by SMS
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at"`
 	
 	Languages      string `gorm:"size:128;not null;default:''" json:"languages"`
 	Occupation     string `gorm:"size:128;not null;default:''" json:"occupation"`
@@ -72,6 +89,11 @@ func (u *User) GetFullName() string {
 	return u.Email
 }
 
+// HasVerifiedPhone reports whether the phone number on file was confirmed with a code
+func (u *User) HasVerifiedPhone() bool {
+	return u.PhoneNumber != "" && u.PhoneVerifiedAt != nil
+}
+
 func (u *User) IsProfileComplete() bool {
 	return u.FirstName != "" && u.LastName != "" && u.PhoneNumber != ""
 }
@@ -122,3 +144,21 @@ type District struct {
 func (District) TableName() string {
 	return "district"
 }
+
+// Profile values users can choose from
+var (
+	Genders         = []string{"Male", "Female", "Other"}
+	MaritalStatuses = []string{"Single", "Married", "Divorced", "Widowed", "Separated"}
+)
+
+// MatchProfileValue returns the allowed value equal to v ignoring case, or ""
+// if there is none
+func MatchProfileValue(allowed []string, v string) string {
+	v = strings.TrimSpace(v)
+	for _, a := range allowed {
+		if strings.EqualFold(a, v) {
+			return a
+		}
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Account lifecycle states stored in User.UserStatus
+const (
+	UserStatusRegistered    = "Registered"
+	UserStatusEmailVerified = "EmailVerified"
+	UserStatusActive        = "Active"
+	UserStatusSuspended     = "Suspended"
+	UserStatusDeactivated   = "Deactivated"
+	UserStatusDeleted       = "Deleted"
+)
+
+// userStatusTransitions lists, for each state, the states it may move to
+var userStatusTransitions = map[string][]string{
+	UserStatusRegistered:    {UserStatusEmailVerified, UserStatusDeleted},
+	UserStatusEmailVerified: {UserStatusActive, UserStatusDeleted},
+	UserStatusActive:        {UserStatusSuspended, UserStatusDeactivated, UserStatusDeleted},
+	UserStatusSuspended:     {UserStatusActive, UserStatusDeactivated, UserStatusDeleted},
+	UserStatusDeactivated:   {UserStatusActive, UserStatusDeleted},
+	UserStatusDeleted:       {},
+}
+
+var (
+	ErrInvalidStatusTransition = errors.New("invalid user status transition")
+	ErrStatusChanged           = errors.New("user status changed concurrently")
+)
+
+// IsValidUserStatus reports whether status is a known lifecycle state
+func IsValidUserStatus(status string) bool {
+	_, ok := userStatusTransitions[status]
+	return ok
+}
+
+// CanTransitionUserStatus reports whether from → to is an allowed move
+func CanTransitionUserStatus(from, to string) bool {
+	for _, allowed := range userStatusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// UserStatusHistory records every lifecycle transition
+type UserStatusHistory struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser     uint      `gorm:"not null;index" json:"cd_user"`
+	FromStatus string    `gorm:"size:24;not null" json:"from_status"`
+	ToStatus   string    `gorm:"size:24;not null" json:"to_status"`
+	CdActor    *uint     `json:"cd_actor"`
+	Reason     string    `gorm:"size:255;not null;default:''" json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (UserStatusHistory) TableName() string {
+	return "user_status_history"
+}
+
+// TransitionStatus moves the user to a new lifecycle state and records who
+// did it and why. This is the only place UserStatus should change. A nil
+// actorID means the system or the user themselves acted.
+func (u *User) TransitionStatus(tx *gorm.DB, to string, actorID *uint, reason string) error {
+	from := u.UserStatus
+	if !CanTransitionUserStatus(from, to) {
+		return fmt.Errorf("%w: %s → %s", ErrInvalidStatusTransition, from, to)
+	}
+
+	// Guard on the old value so concurrent transitions cannot both win
+	result := tx.Model(&User{}).
+		Where("cd_user = ? AND user_status = ?", u.CdUser, from).
+		Update("user_status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStatusChanged
+	}
+
+	history := UserStatusHistory{
+		CdUser:     u.CdUser,
+		FromStatus: from,
+		ToStatus:   to,
+		CdActor:    actorID,
+		Reason:     reason,
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return err
+	}
+
+	u.UserStatus = to
+	return nil
+}
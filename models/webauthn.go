@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// WebAuthnCredential is a passkey or security key registered by a user. It
+// can log the user in on its own or serve as their second factor.
+type WebAuthnCredential struct {
+	ID              uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CdUser          uint       `gorm:"not null;index" json:"cd_user"`
+	CredentialID    []byte     `gorm:"not null;uniqueIndex" json:"-"`
+	PublicKey       []byte     `gorm:"not null" json:"-"`
+	AttestationType string     `gorm:"size:32;not null;default:''" json:"attestation_type"`
+	Transports      string     `gorm:"size:128;not null;default:''" json:"transports"`
+	AAGUID          []byte     `gorm:"column:aaguid" json:"-"`
+	SignCount       uint32     `gorm:"not null;default:0" json:"sign_count"`
+	CloneWarning    bool       `gorm:"not null;default:false" json:"clone_warning"`
+	UserVerified    bool       `gorm:"not null;default:false" json:"user_verified"`
+	BackupEligible  bool       `gorm:"not null;default:false" json:"backup_eligible"`
+	BackupState     bool       `gorm:"not null;default:false" json:"backup_state"`
+	Name            string     `gorm:"size:64;not null;default:''" json:"name"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// Kinds of WebAuthn ceremony
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+	WebAuthnCeremonyMFA          = "mfa"
+)
+
+// WebAuthnCeremony holds the challenge of a registration or login in
+// progress. It is deleted when the browser's response is checked.
+type WebAuthnCeremony struct {
+	ID          string    `gorm:"primaryKey;size:36" json:"id"`
+	CdUser      *uint     `gorm:"index" json:"cd_user"`
+	Kind        string    `gorm:"size:16;not null" json:"kind"`
+	SessionData string    `gorm:"type:text;not null" json:"-"`
+	ExpiresAt   time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (WebAuthnCeremony) TableName() string {
+	return "webauthn_ceremonies"
+}
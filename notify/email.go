@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"context"
+	"vcm-medical-platform/config"
+
+	"gopkg.in/mail.v2"
+)
+
+// EmailSender delivers HTML email through SMTP
+type EmailSender struct {
+	cfg config.SMTPConfig
+}
+
+func NewEmailSender(cfg config.SMTPConfig) *EmailSender {
+	return &EmailSender{cfg: cfg}
+}
+
+func (s *EmailSender) Send(ctx context.Context, msg Message) (string, error) {
+	m := mail.NewMessage()
+	m.SetHeader("From", s.cfg.From)
+	m.SetHeader("To", msg.To)
+	m.SetHeader("Subject", msg.Subject)
+	m.SetBody("text/html", msg.HTML)
+
+	d := mail.NewDialer(s.cfg.Host, s.cfg.Port, s.cfg.User, s.cfg.Pass)
+
+	// SMTP assigns no message ID we can report back
+	return "", d.DialAndSend(m)
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// fakeSenderLimit is how many recent messages a FakeSender keeps
+const fakeSenderLimit = 100
+
+// FakeSender records messages instead of delivering them, for development and tests
+type FakeSender struct {
+	mu       sync.Mutex
+	messages []Message
+	// logValues prints codes and links so they can be read in development
+	logValues bool
+}
+
+func NewFakeSender() *FakeSender {
+	return &FakeSender{}
+}
+
+func (f *FakeSender) Send(ctx context.Context, msg Message) (string, error) {
+	f.mu.Lock()
+	f.messages = append(f.messages, msg)
+	if len(f.messages) > fakeSenderLimit {
+		f.messages = append([]Message(nil), f.messages[len(f.messages)-fakeSenderLimit:]...)
+	}
+	f.mu.Unlock()
+
+	// Params carry codes and links, so only their names are logged outside development
+	names := make([]string, 0, len(msg.Params))
+	for name := range msg.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if f.logValues {
+		for i, name := range names {
+			names[i] = name + "=" + msg.Params[name]
+		}
+		log.Printf("📧 [%s] %s to %s (not sent; %s)", msg.Channel, msg.Template, msg.To, strings.Join(names, ", "))
+	} else {
+		log.Printf("📧 [%s] %s to %s (not sent; params: %s)",
+			msg.Channel, msg.Template, maskRecipient(msg.Channel, msg.To), strings.Join(names, ", "))
+	}
+	return "fake-" + uuid.NewString(), nil
+}
+
+// Messages returns the most recent messages sent
+func (f *FakeSender) Messages() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.messages...)
+}
+
+// Last returns the most recent message sent to recipient
+func (f *FakeSender) Last(to string) (Message, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.messages) - 1; i >= 0; i-- {
+		if f.messages[i].To == to {
+			return f.messages[i], true
+		}
+	}
+	return Message{}, false
+}
+
+// Reset forgets recorded messages
+func (f *FakeSender) Reset() {
+	f.mu.Lock()
+	f.messages = nil
+	f.mu.Unlock()
+}
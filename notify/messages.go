@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"time"
+	"vcm-medical-platform/models"
+)
+
+// SendOTP delivers a one-time code. Codes that prove ownership of the email
+// address always go by email; everything else follows ChannelFor.
+func SendOTP(ctx context.Context, user *models.User, purpose models.OTPPurpose, code string) error {
+	channel := models.NotificationChannelEmail
+	if purpose != models.OTPPurposeRegistration && purpose != models.OTPPurposeEmailChange {
+		channel = ChannelFor(user)
+	}
+
+	return Deliver(ctx, Message{
+		Channel:  channel,
+		To:       Recipient(user, channel),
+		UserID:   &user.CdUser,
+		Template: TemplateOTP,
+		Params:   map[string]string{"code": code},
+		Subject:  "VCM Medical Platform - Verification Code",
+		HTML: fmt.Sprintf(`
+		<h2>VCM Medical Platform</h2>
+		<p>Your verification code is: <strong>%s</strong></p>
+		<p>This code will expire in 10 minutes.</p>
+		<p>If you didn't request this code, please ignore this email.</p>
+	`, code),
+	})
+}
+
+// SendInvitation emails a staff invitation with its registration link
+func SendInvitation(ctx context.Context, email, userTypeName, link string) error {
+	return Deliver(ctx, Message{
+		Channel:  models.NotificationChannelEmail,
+		To:       email,
+		Template: TemplateInvitation,
+		Params:   map[string]string{"user_type": userTypeName, "link": link},
+		Subject:  "VCM Medical Platform - Invitation",
+		HTML: fmt.Sprintf(`
+		<h2>VCM Medical Platform</h2>
+		<p>You have been invited to join VCM Medical Platform as <strong>%s</strong>.</p>
+		<p><a href="%s">Accept your invitation</a></p>
+		<p>If you weren't expecting this invitation, please ignore this email.</p>
+	`, html.EscapeString(userTypeName), html.EscapeString(link)),
+	})
+}
+
+// SendLoginAlert emails the user about a sign-in from a device or country
+// they have not used before
+func SendLoginAlert(ctx context.Context, user *models.User, event *models.LoginEvent) error {
+	location := event.IPAddress
+	if event.Country != "" {
+		location = fmt.Sprintf("%s (%s)", event.IPAddress, event.Country)
+	}
+	when := event.CreatedAt.UTC().Format("2006-01-02 15:04 UTC")
+
+	return Deliver(ctx, Message{
+		Channel:  models.NotificationChannelEmail,
+		To:       user.Email,
+		UserID:   &user.CdUser,
+		Template: TemplateLoginAlert,
+		Params:   map[string]string{"device": event.Device, "location": location, "time": when},
+		Subject:  "VCM Medical Platform - New sign-in to your account",
+		HTML: fmt.Sprintf(`
+		<h2>VCM Medical Platform</h2>
+		<p>Your account was just signed in to from a new device or location.</p>
+		<p>Device: <strong>%s</strong><br>Location: <strong>%s</strong><br>Time: <strong>%s</strong></p>
+		<p>If this was you, you can ignore this email.</p>
+		<p>If it wasn't, change your password now and log out your other sessions from your account settings.</p>
+	`, html.EscapeString(event.Device), html.EscapeString(location), when),
+	})
+}
+
+// SendContactVerification delivers a code to an email address or phone number
+// the user wants to switch to, proving they control it
+func SendContactVerification(ctx context.Context, user *models.User, channel, to, code string) error {
+	return Deliver(ctx, Message{
+		Channel:  channel,
+		To:       to,
+		UserID:   &user.CdUser,
+		Template: TemplateOTP,
+		Params:   map[string]string{"code": code},
+		Subject:  "VCM Medical Platform - Confirm your new contact details",
+		HTML: fmt.Sprintf(`
+		<h2>VCM Medical Platform</h2>
+		<p>Your verification code is: <strong>%s</strong></p>
+		<p>Enter it to use this address for your account. This code will expire in 10 minutes.</p>
+		<p>If you didn't request this change, please ignore this email.</p>
+	`, code),
+	})
+}
+
+// SendContactChanged emails the account's previous address that its email or
+// phone number changed, with a link to undo the change
+func SendContactChanged(ctx context.Context, user *models.User, to, kind, newValue, undoLink string) error {
+	what, channel := "email address", models.NotificationChannelEmail
+	if kind == models.ContactKindPhone {
+		what, channel = "phone number", models.NotificationChannelSMS
+	}
+	masked := maskRecipient(channel, newValue)
+
+	return Deliver(ctx, Message{
+		Channel:  models.NotificationChannelEmail,
+		To:       to,
+		UserID:   &user.CdUser,
+		Template: TemplateContactChanged,
+		Params:   map[string]string{"kind": kind, "new_value": masked, "link": undoLink},
+		Subject:  "VCM Medical Platform - Your " + what + " was changed",
+		HTML: fmt.Sprintf(`
+		<h2>VCM Medical Platform</h2>
+		<p>The %s on your account was changed to <strong>%s</strong>.</p>
+		<p>If you made this change, you can ignore this email.</p>
+		<p>If you didn't, <a href="%s">undo the change</a> and then reset your password. The link is valid for 7 days.</p>
+	`, what, html.EscapeString(masked), html.EscapeString(undoLink)),
+	})
+}
+
+// SendLoginLink emails a passwordless login link together with a code that
+// can be typed instead, for when the link opens in another browser
+func SendLoginLink(ctx context.Context, user *models.User, code, link string, ttl time.Duration) error {
+	minutes := int(ttl.Minutes())
+	if minutes < 1 {
+		minutes = 1
+	}
+
+	return Deliver(ctx, Message{
+		Channel:  models.NotificationChannelEmail,
+		To:       user.Email,
+		UserID:   &user.CdUser,
+		Template: TemplateLoginLink,
+		Params:   map[string]string{"code": code, "link": link},
+		Subject:  "VCM Medical Platform - Your login link",
+		HTML: fmt.Sprintf(`
+		<h2>VCM Medical Platform</h2>
+		<p><a href="%s">Log in to VCM Medical Platform</a></p>
+		<p>Or enter this code on the login page: <strong>%s</strong></p>
+		<p>The link and code work once, on the device where you asked for them, and expire in %d minutes.</p>
+		<p>If you didn't try to log in, please ignore this email.</p>
+	`, html.EscapeString(link), code, minutes),
+	})
+}
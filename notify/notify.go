@@ -0,0 +1,188 @@
+// Package notify delivers one-time codes and other messages over email, SMS
+// and WeChat, recording the outcome of every delivery.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"vcm-medical-platform/config"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+)
+
+// Message templates; SMS and WeChat map these to provider template IDs
+const (
+	TemplateOTP        = "otp"
+	TemplateInvitation = "invitation"
+	TemplateLoginAlert = "login_alert"
+	// TemplateContactChanged tells the previous address about an email or phone change
+	TemplateContactChanged = "contact_changed"
+	// TemplateLoginLink carries a passwordless login link and code
+	TemplateLoginLink = "login_link"
+)
+
+// Message is a channel-agnostic notification. Email uses Subject and HTML;
+// SMS and WeChat render Template with Params on the provider side.
+type Message struct {
+	Channel  string
+	To       string
+	UserID   *uint
+	Template string
+	Params   map[string]string
+	Subject  string
+	HTML     string
+}
+
+// Sender delivers messages on one channel and returns the provider's message ID
+type Sender interface {
+	Send(ctx context.Context, msg Message) (string, error)
+}
+
+var ErrChannelUnavailable = errors.New("notification channel not available")
+
+var (
+	senders = map[string]Sender{}
+	fake    *FakeSender
+)
+
+// Init wires a sender for every configured channel. In fake mode, or for
+// email without SMTP credentials outside production, messages are recorded
+// instead of sent.
+func Init(cfg *config.Config) {
+	senders = map[string]Sender{}
+	fake = NewFakeSender()
+	// Without a mail server, developers read their codes from the log
+	fake.logValues = cfg.IsDevelopment()
+
+	if cfg.Notify.Fake {
+		log.Println("📨 Notifications are faked: messages are recorded, not sent")
+		senders[models.NotificationChannelEmail] = fake
+		senders[models.NotificationChannelSMS] = fake
+		senders[models.NotificationChannelWeChat] = fake
+		return
+	}
+
+	if cfg.SMTP.IsConfigured() {
+		senders[models.NotificationChannelEmail] = NewEmailSender(cfg.SMTP)
+	} else {
+		log.Println("⚠️  SMTP not configured, emails will be recorded, not sent")
+		senders[models.NotificationChannelEmail] = fake
+	}
+	if cfg.Notify.SMS.IsConfigured() {
+		senders[models.NotificationChannelSMS] = NewAliyunSMSSender(cfg.Notify.SMS)
+	}
+	if cfg.Notify.WeChat.IsConfigured() {
+		senders[models.NotificationChannelWeChat] = NewWeChatSender(cfg.Notify.WeChat)
+	}
+}
+
+// Fake returns the recording sender used in fake mode and for unconfigured email
+func Fake() *FakeSender {
+	return fake
+}
+
+// Available reports whether messages can be sent on channel
+func Available(channel string) bool {
+	_, ok := senders[channel]
+	return ok
+}
+
+// Recipient returns the user's address on channel, or "" if they have none
+func Recipient(user *models.User, channel string) string {
+	switch channel {
+	case models.NotificationChannelEmail:
+		return user.Email
+	case models.NotificationChannelSMS:
+		// An unconfirmed number may belong to someone else
+		if !user.HasVerifiedPhone() {
+			return ""
+		}
+		return user.PhoneNumber
+	case models.NotificationChannelWeChat:
+		return user.WechatOpenID
+	}
+	return ""
+}
+
+// ChannelFor picks the channel for the user: their preference when it is
+// usable, otherwise SMS for users in China with a verified phone number,
+// otherwise email
+func ChannelFor(user *models.User) string {
+	if ch := user.NotificationChannel; ch != "" && Available(ch) && Recipient(user, ch) != "" {
+		return ch
+	}
+	if user.CdCountry == models.CountryChina && user.HasVerifiedPhone() && Available(models.NotificationChannelSMS) {
+		return models.NotificationChannelSMS
+	}
+	return models.NotificationChannelEmail
+}
+
+// Deliver sends msg on its channel and records the outcome
+func Deliver(ctx context.Context, msg Message) error {
+	sender, ok := senders[msg.Channel]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrChannelUnavailable, msg.Channel)
+	}
+
+	delivery := models.NotificationDelivery{
+		CdUser:    msg.UserID,
+		Channel:   msg.Channel,
+		Recipient: maskRecipient(msg.Channel, msg.To),
+		Template:  msg.Template,
+		Status:    models.DeliveryStatusPending,
+	}
+	if err := database.DB.Create(&delivery).Error; err != nil {
+		// Losing the record must not stop the code from reaching the user
+		log.Printf("Error recording notification delivery: %v", err)
+	}
+
+	providerID, sendErr := sender.Send(ctx, msg)
+
+	updates := map[string]interface{}{"provider_message_id": providerID}
+	if sendErr != nil {
+		updates["status"] = models.DeliveryStatusFailed
+		updates["error"] = truncate(sendErr.Error(), 512)
+	} else {
+		updates["status"] = models.DeliveryStatusSent
+		updates["sent_at"] = time.Now()
+	}
+	if delivery.ID != 0 {
+		if err := database.DB.Model(&delivery).Updates(updates).Error; err != nil {
+			log.Printf("Error updating notification delivery %d: %v", delivery.ID, err)
+		}
+	}
+
+	return sendErr
+}
+
+// maskRecipient keeps enough of an address to recognise it in support tools
+func maskRecipient(channel, to string) string {
+	switch channel {
+	case models.NotificationChannelEmail:
+		at := strings.LastIndex(to, "@")
+		if at <= 0 {
+			return "***"
+		}
+		return to[:1] + "***" + to[at:]
+	case models.NotificationChannelSMS:
+		if len(to) <= 4 {
+			return "***"
+		}
+		return "***" + to[len(to)-4:]
+	}
+	if len(to) <= 6 {
+		return "***"
+	}
+	return to[:3] + "***" + to[len(to)-3:]
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+	"vcm-medical-platform/config"
+	"vcm-medical-platform/database"
+	"vcm-medical-platform/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupFake records deliveries in a fresh SQLite database and fakes every channel
+func setupFake(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.NotificationDelivery{}); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	Init(&config.Config{Environment: "test", Notify: config.NotifyConfig{Fake: true}})
+}
+
+func TestSendOTPIsRecorded(t *testing.T) {
+	setupFake(t)
+	user := &models.User{CdUser: 7, Email: "alice@vcm.test"}
+
+	if err := SendOTP(context.Background(), user, models.OTPPurposeRegistration, "123456"); err != nil {
+		t.Fatalf("SendOTP: %v", err)
+	}
+
+	msg, ok := Fake().Last(user.Email)
+	if !ok {
+		t.Fatal("no message recorded for the user")
+	}
+	if msg.Channel != models.NotificationChannelEmail || msg.Template != TemplateOTP || msg.Params["code"] != "123456" {
+		t.Fatalf("recorded message = %+v, want the email OTP 123456", msg)
+	}
+
+	var delivery models.NotificationDelivery
+	if err := database.DB.First(&delivery).Error; err != nil {
+		t.Fatalf("load delivery: %v", err)
+	}
+	if delivery.Status != models.DeliveryStatusSent || delivery.Recipient != "a***@vcm.test" {
+		t.Fatalf("delivery = %+v, want sent to a masked address", delivery)
+	}
+}
+
+func TestSendLoginLinkIsRecorded(t *testing.T) {
+	setupFake(t)
+	user := &models.User{CdUser: 7, Email: "alice@vcm.test"}
+
+	if err := SendLoginLink(context.Background(), user, "654321", "https://app.vcm.test/login/abc", 0); err != nil {
+		t.Fatalf("SendLoginLink: %v", err)
+	}
+
+	msg, ok := Fake().Last(user.Email)
+	if !ok || msg.Template != TemplateLoginLink {
+		t.Fatalf("recorded message = %+v, want a login link", msg)
+	}
+	if msg.Params["code"] != "654321" || msg.Params["link"] != "https://app.vcm.test/login/abc" {
+		t.Fatalf("params = %v, want the code and link", msg.Params)
+	}
+}
+
+func TestFakeSenderKeepsRecentMessages(t *testing.T) {
+	fake := NewFakeSender()
+	for i := 0; i < fakeSenderLimit+5; i++ {
+		fake.Send(context.Background(), Message{To: fmt.Sprintf("user%d@vcm.test", i)})
+	}
+
+	messages := fake.Messages()
+	if len(messages) != fakeSenderLimit {
+		t.Fatalf("kept %d messages, want %d", len(messages), fakeSenderLimit)
+	}
+	if messages[0].To != "user5@vcm.test" {
+		t.Fatalf("oldest kept message is to %s, want user5@vcm.test", messages[0].To)
+	}
+	if _, ok := fake.Last("user0@vcm.test"); ok {
+		t.Fatal("dropped message is still returned by Last")
+	}
+
+	fake.Reset()
+	if len(fake.Messages()) != 0 {
+		t.Fatal("Reset kept messages")
+	}
+}
+
+func TestChannelForNeedsVerifiedPhone(t *testing.T) {
+	setupFake(t)
+	verifiedAt := time.Now()
+
+	cases := []struct {
+		name string
+		user models.User
+		want string
+	}{
+		{"unverified number in China", models.User{CdCountry: models.CountryChina, PhoneNumber: "+8613800000000"}, models.NotificationChannelEmail},
+		{"verified number in China", models.User{CdCountry: models.CountryChina, PhoneNumber: "+8613800000000", PhoneVerifiedAt: &verifiedAt}, models.NotificationChannelSMS},
+		{"SMS preferred, unverified", models.User{PhoneNumber: "+8613800000000", NotificationChannel: models.NotificationChannelSMS}, models.NotificationChannelEmail},
+		{"SMS preferred, verified", models.User{PhoneNumber: "+8613800000000", PhoneVerifiedAt: &verifiedAt, NotificationChannel: models.NotificationChannelSMS}, models.NotificationChannelSMS},
+	}
+	for _, tc := range cases {
+		if got := ChannelFor(&tc.user); got != tc.want {
+			t.Errorf("%s: ChannelFor = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestResetCodeAvoidsUnverifiedPhone(t *testing.T) {
+	setupFake(t)
+	user := &models.User{CdUser: 7, Email: "alice@vcm.test", CdCountry: models.CountryChina, PhoneNumber: "+8613800000000"}
+
+	if err := SendOTP(context.Background(), user, models.OTPPurposeReset, "111222"); err != nil {
+		t.Fatalf("SendOTP: %v", err)
+	}
+	if _, ok := Fake().Last(user.PhoneNumber); ok {
+		t.Fatal("reset code was sent to an unverified phone number")
+	}
+	if msg, ok := Fake().Last(user.Email); !ok || msg.Params["code"] != "111222" {
+		t.Fatalf("reset code not emailed: %+v", msg)
+	}
+}
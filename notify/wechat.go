@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"vcm-medical-platform/config"
+)
+
+const wechatAPIBase = "https://api.weixin.qq.com/cgi-bin"
+
+// WeChat error codes meaning the cached access token must be refreshed
+const (
+	wechatErrInvalidToken = 40001
+	wechatErrTokenExpired = 42001
+)
+
+// WeChatSender sends Official Account template messages to a user's openid
+type WeChatSender struct {
+	cfg       config.WeChatMPConfig
+	templates map[string]string
+	client    *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+func NewWeChatSender(cfg config.WeChatMPConfig) *WeChatSender {
+	return &WeChatSender{
+		cfg:       cfg,
+		templates: map[string]string{TemplateOTP: cfg.OTPTemplateID},
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type wechatError struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (e wechatError) Error() string {
+	return fmt.Sprintf("wechat: %d: %s", e.ErrCode, e.ErrMsg)
+}
+
+type wechatTemplateValue struct {
+	Value string `json:"value"`
+}
+
+func (s *WeChatSender) Send(ctx context.Context, msg Message) (string, error) {
+	templateID, ok := s.templates[msg.Template]
+	if !ok {
+		return "", fmt.Errorf("no WeChat template for %q", msg.Template)
+	}
+	if msg.To == "" {
+		return "", fmt.Errorf("wechat: user has no linked openid")
+	}
+
+	data := map[string]wechatTemplateValue{}
+	for k, v := range msg.Params {
+		data[k] = wechatTemplateValue{Value: v}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"touser":      msg.To,
+		"template_id": templateID,
+		"data":        data,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	msgID, err := s.sendTemplate(ctx, body)
+	if we, ok := err.(wechatError); ok && (we.ErrCode == wechatErrInvalidToken || we.ErrCode == wechatErrTokenExpired) {
+		// The token was invalidated early, e.g. by another client; retry once
+		s.mu.Lock()
+		s.accessToken = ""
+		s.mu.Unlock()
+		msgID, err = s.sendTemplate(ctx, body)
+	}
+	return msgID, err
+}
+
+func (s *WeChatSender) sendTemplate(ctx context.Context, body []byte) (string, error) {
+	token, err := s.token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		wechatAPIBase+"/message/template/send?access_token="+url.QueryEscape(token), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		wechatError
+		MsgID int64 `json:"msgid"`
+	}
+	if err := s.do(req, &result); err != nil {
+		return "", err
+	}
+	if result.ErrCode != 0 {
+		return "", result.wechatError
+	}
+	return fmt.Sprint(result.MsgID), nil
+}
+
+// token returns a cached access token, fetching a new one shortly before expiry
+func (s *WeChatSender) token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken != "" && time.Now().Before(s.expiresAt) {
+		return s.accessToken, nil
+	}
+
+	q := url.Values{}
+	q.Set("grant_type", "client_credential")
+	q.Set("appid", s.cfg.AppID)
+	q.Set("secret", s.cfg.AppSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wechatAPIBase+"/token?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		wechatError
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := s.do(req, &result); err != nil {
+		return "", err
+	}
+	if result.ErrCode != 0 {
+		return "", result.wechatError
+	}
+
+	s.accessToken = result.AccessToken
+	s.expiresAt = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - 5*time.Minute)
+	return s.accessToken, nil
+}
+
+func (s *WeChatSender) do(req *http.Request, out interface{}) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("wechat: unreadable response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	return nil
+}
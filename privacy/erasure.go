@@ -0,0 +1,212 @@
+package privacy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"vcm-medical-platform/models"
+
+	"gorm.io/gorm"
+)
+
+// ErasedContent replaces chat messages whose author was erased
+const ErasedContent = "[erased]"
+
+// ErasedEmail is the placeholder address of an erased account; it keeps the
+// unique index satisfied and can never receive mail
+func ErasedEmail(userID uint) string {
+	return fmt.Sprintf("erased-%d@erased.invalid", userID)
+}
+
+var errNotPending = errors.New("data request is no longer pending")
+
+// erasedTables hold nothing we must keep; the user's rows are deleted outright
+var erasedTables = []interface{}{
+	&models.RefreshToken{},
+	&models.Session{},
+	&models.OTPChallenge{},
+	&models.OTPThrottle{},
+	&models.UserTOTP{},
+	&models.MFARecoveryCode{},
+	&models.UserIdentity{},
+	&models.OAuthAuthorizationCode{},
+	&models.OAuthAccessToken{},
+	&models.OAuthConsent{},
+	&models.APIKey{},
+	&models.UserPermissionOverride{},
+	&models.NotificationDelivery{},
+	&models.ContactChange{},
+	&models.LoginLink{},
+	&models.WebAuthnCredential{},
+	&models.WebAuthnCeremony{},
+}
+
+// Erase anonymizes a user in place. The users row stays, stripped of
+// personal data, so records we must retain keep a valid owner:
+//
+//   - assessments and appointments (medical records, including doctor notes)
+//   - orders (financial records)
+//   - status history and data requests (evidence of how the account was handled)
+//
+// Everything else linked to the user is deleted, including chat rooms they
+// opened as a patient. Messages they wrote in other rooms are redacted.
+func Erase(tx *gorm.DB, userID uint, actorID *uint, reason string) error {
+	var user models.User
+	if err := tx.Unscoped().Where("cd_user = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+
+	if user.UserStatus != models.UserStatusDeleted {
+		if err := user.TransitionStatus(tx, models.UserStatusDeleted, actorID, reason); err != nil {
+			return err
+		}
+	}
+
+	// The empty password matches no hash, so the account can never log in
+	if err := tx.Unscoped().Model(&models.User{}).Where("cd_user = ?", userID).Updates(map[string]interface{}{
+		"email":                ErasedEmail(userID),
+		"password":             "",
+		"first_name":           "",
+		"last_name":            "",
+		"gender":               "Other",
+		"phone_number":         "",
+		"phone_verified_at":    nil,
+		"date_of_birth":        time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+		"wechat_id":            "",
+		"wechat_openid":        "",
+		"notification_channel": "",
+		"languages":            "",
+		"occupation":           "",
+		"religion":             "",
+		"height_cm":            0,
+		"weight_kg":            0,
+		"marital_status":       "Single",
+		"no_children":          0,
+		"cd_country":           0,
+		"cd_state":             0,
+		"cd_city":              0,
+		"cd_district":          0,
+		"cd_street":            0,
+		"street_address":       "",
+		"postal_code":          "",
+	}).Error; err != nil {
+		return err
+	}
+
+	for _, model := range erasedTables {
+		if err := tx.Where("cd_user = ?", userID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	// Failed attempts against the address may predate the account; the
+	// login history stores addresses lower-cased
+	if err := tx.Where("cd_user = ? OR email = ?", userID, strings.ToLower(strings.TrimSpace(user.Email))).
+		Delete(&models.LoginEvent{}).Error; err != nil {
+		return err
+	}
+
+	// Consent records are evidence of what was agreed, so only where they were given is removed
+	if err := tx.Model(&models.UserConsent{}).Where("cd_user = ?", userID).Updates(map[string]interface{}{
+		"ip_address": "",
+		"user_agent": "",
+	}).Error; err != nil {
+		return err
+	}
+
+	// Invitations carry the address the user was invited at
+	if err := tx.Model(&models.Invitation{}).
+		Where("accepted_by = ?", userID).
+		Update("email", ErasedEmail(userID)).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Exec("DELETE FROM chat_room WHERE cd_patient = ?", userID).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("UPDATE chat_message SET content = ? WHERE cd_user = ?", ErasedContent, userID).Error; err != nil {
+		return err
+	}
+
+	// Earlier exports are copies of the data we just removed
+	return tx.Model(&models.DataRequest{}).
+		Where("cd_user = ? AND archive IS NOT NULL", userID).
+		Updates(map[string]interface{}{"archive": nil, "status": models.DataRequestExpired}).Error
+}
+
+// ProcessDueRequests carries out erasures whose grace period has passed and
+// drops export archives past their expiry
+func ProcessDueRequests(db *gorm.DB) error {
+	now := time.Now()
+
+	var due []models.DataRequest
+	if err := db.Where("kind = ? AND status = ? AND scheduled_for <= ?",
+		models.DataRequestErasure, models.DataRequestPending, now).
+		Order("scheduled_for").Find(&due).Error; err != nil {
+		return fmt.Errorf("failed to load due erasure requests: %w", err)
+	}
+
+	for _, req := range due {
+		reason := fmt.Sprintf("Erasure request #%d", req.ID)
+		if req.Reason != "" {
+			reason += ": " + req.Reason
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Claim the request so a cancellation or a concurrent run cannot race us
+			claim := tx.Model(&models.DataRequest{}).
+				Where("id = ? AND status = ?", req.ID, models.DataRequestPending).
+				Updates(map[string]interface{}{
+					"status":       models.DataRequestCompleted,
+					"completed_at": time.Now(),
+				})
+			if claim.Error != nil {
+				return claim.Error
+			}
+			if claim.RowsAffected == 0 {
+				return errNotPending
+			}
+			return Erase(tx, req.CdUser, req.RequestedBy, reason)
+		})
+		if errors.Is(err, errNotPending) {
+			continue
+		}
+		if err != nil {
+			log.Printf("⚠️  Erasure request %d for user %d failed: %v", req.ID, req.CdUser, err)
+			db.Model(&models.DataRequest{}).Where("id = ?", req.ID).Updates(map[string]interface{}{
+				"status": models.DataRequestFailed,
+				"error":  truncate(err.Error(), 512),
+			})
+			continue
+		}
+		log.Printf("🔒 Erased personal data of user %d (request %d)", req.CdUser, req.ID)
+	}
+
+	if err := db.Model(&models.DataRequest{}).
+		Where("kind = ? AND status = ? AND expires_at <= ?", models.DataRequestExport, models.DataRequestCompleted, now).
+		Updates(map[string]interface{}{"archive": nil, "status": models.DataRequestExpired}).Error; err != nil {
+		return fmt.Errorf("failed to expire export archives: %w", err)
+	}
+	return nil
+}
+
+// RunScheduler processes due requests every interval until the process exits
+func RunScheduler(db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := ProcessDueRequests(db); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,193 @@
+// Package privacy handles data subject requests: exporting everything held
+// about a user, and erasing their personal data while keeping the records
+// the platform is legally required to retain.
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+	"vcm-medical-platform/models"
+
+	"gorm.io/gorm"
+)
+
+// exportFile is one JSON document in the archive
+type exportFile struct {
+	Name        string
+	Description string
+	Load        func(db *gorm.DB, userID uint) (interface{}, error)
+}
+
+// exportFiles lists the archive contents. Tables without a Go model are read
+// as rows so every column the user could ask about is included.
+var exportFiles = []exportFile{
+	{"profile.json", "Your account and profile", loadProfile},
+	{"assessments.json", "Health assessments you submitted", rowsWhere("af_psoriasis", "cd_user = ?")},
+	{"appointments.json", "Appointments you booked, and the times of those you attended as a doctor", loadAppointments},
+	{"orders.json", "Your orders", rowsWhere(`"order"`, "cd_user = ?")},
+	{"chat.json", "Your chat rooms with their messages, and messages you wrote in patients' rooms", loadChat},
+	{"sessions.json", "Devices you logged in from", modelsWhere[models.Session]("cd_user = ?")},
+	{"login_history.json", "Logins and failed login attempts on your account", modelsWhere[models.LoginEvent]("cd_user = ?")},
+	{"passkeys.json", "Passkeys and security keys you registered (public keys are not included)", modelsWhere[models.WebAuthnCredential]("cd_user = ?")},
+	{"linked_accounts.json", "External accounts linked for login", modelsWhere[models.UserIdentity]("cd_user = ?")},
+	{"connected_apps.json", "Partner portals you allowed to sign you in", modelsWhere[models.OAuthConsent]("cd_user = ?")},
+	{"api_keys.json", "API keys you created (the keys themselves are never stored)", modelsWhere[models.APIKey]("cd_user = ?")},
+	{"notifications.json", "Messages we sent you (content is not stored)", modelsWhere[models.NotificationDelivery]("cd_user = ?")},
+	{"consents.json", "Documents you accepted and consents you withdrew", modelsWhere[models.UserConsent]("cd_user = ?")},
+	{"contact_changes.json", "Changes to your email address and phone number", modelsWhere[models.ContactChange]("cd_user = ?")},
+	{"status_history.json", "Changes to your account status", modelsWhere[models.UserStatusHistory]("cd_user = ?")},
+	{"data_requests.json", "Your earlier export and erasure requests", loadDataRequests},
+}
+
+// BuildExport collects the user's data into a zip archive of JSON files
+func BuildExport(db *gorm.DB, userID uint) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	manifest := map[string]interface{}{
+		"user_id":      userID,
+		"generated_at": time.Now().UTC(),
+	}
+	contents := map[string]string{}
+
+	for _, file := range exportFiles {
+		data, err := file.Load(db, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", file.Name, err)
+		}
+		if err := writeJSON(archive, file.Name, data); err != nil {
+			return nil, err
+		}
+		contents[file.Name] = file.Description
+	}
+
+	manifest["files"] = contents
+	if err := writeJSON(archive, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(archive *zip.Writer, name string, data interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+func loadProfile(db *gorm.DB, userID uint) (interface{}, error) {
+	var user models.User
+	if err := db.Unscoped().Preload("UserType").Where("cd_user = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// loadAppointments keeps a doctor's appointments to their scheduling details;
+// the patient and the notes belong to the patient's own data
+func loadAppointments(db *gorm.DB, userID uint) (interface{}, error) {
+	asPatient := []map[string]interface{}{}
+	if err := db.Table("appointments").Where("cd_user = ?", userID).
+		Order("created_at").Find(&asPatient).Error; err != nil {
+		return nil, err
+	}
+
+	asDoctor := []map[string]interface{}{}
+	if err := db.Table("appointments").
+		Select("cd_appointment", "appointment_date", "appointment_time", "duration_minutes", "status", "created_at").
+		Where("cd_doctor = ?", userID).
+		Order("created_at").Find(&asDoctor).Error; err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"as_patient": asPatient,
+		"as_doctor":  asDoctor,
+	}, nil
+}
+
+// loadChat exports the user's own rooms in full. In rooms they staffed for
+// a patient, only the messages they wrote are included.
+func loadChat(db *gorm.DB, userID uint) (interface{}, error) {
+	own := []map[string]interface{}{}
+	if err := db.Table("chat_room").Where("cd_patient = ?", userID).
+		Order("created_at").Find(&own).Error; err != nil {
+		return nil, err
+	}
+	for _, room := range own {
+		var messages []map[string]interface{}
+		if err := db.Table("chat_message").
+			Where("cd_chat_room = ?", room["cd_chat_room"]).
+			Order("created_at").Find(&messages).Error; err != nil {
+			return nil, err
+		}
+		room["messages"] = messages
+	}
+
+	staffed := []map[string]interface{}{}
+	if err := db.Table("chat_room").
+		Select("cd_chat_room", "cd_room_type", "status", "created_at").
+		Where("cd_staff = ? AND cd_patient <> ?", userID, userID).
+		Order("created_at").Find(&staffed).Error; err != nil {
+		return nil, err
+	}
+	for _, room := range staffed {
+		var messages []map[string]interface{}
+		if err := db.Table("chat_message").
+			Where("cd_chat_room = ? AND cd_user = ?", room["cd_chat_room"], userID).
+			Order("created_at").Find(&messages).Error; err != nil {
+			return nil, err
+		}
+		room["messages"] = messages
+	}
+
+	return map[string]interface{}{
+		"rooms":         own,
+		"staffed_rooms": staffed,
+	}, nil
+}
+
+// loadDataRequests leaves out earlier archives, which would nest one export in the next
+func loadDataRequests(db *gorm.DB, userID uint) (interface{}, error) {
+	requests := []models.DataRequest{}
+	err := db.Omit("archive").Where("cd_user = ?", userID).Order("created_at").Find(&requests).Error
+	return requests, err
+}
+
+// rowsWhere loads raw rows of table; every ? in condition is bound to the user ID
+func rowsWhere(table, condition string) func(*gorm.DB, uint) (interface{}, error) {
+	return func(db *gorm.DB, userID uint) (interface{}, error) {
+		rows := []map[string]interface{}{}
+		err := db.Table(table).Where(condition, userArgs(condition, userID)...).Order("created_at").Find(&rows).Error
+		return rows, err
+	}
+}
+
+// modelsWhere loads models of type T; every ? in condition is bound to the user ID
+func modelsWhere[T any](condition string) func(*gorm.DB, uint) (interface{}, error) {
+	return func(db *gorm.DB, userID uint) (interface{}, error) {
+		rows := []T{}
+		err := db.Where(condition, userArgs(condition, userID)...).Order("created_at").Find(&rows).Error
+		return rows, err
+	}
+}
+
+func userArgs(condition string, userID uint) []interface{} {
+	var args []interface{}
+	for _, r := range condition {
+		if r == '?' {
+			args = append(args, userID)
+		}
+	}
+	return args
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps counters in process memory; use it when one instance serves all traffic
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]*memoryCounter
+	lastPrune time.Time
+}
+
+type memoryCounter struct {
+	start    time.Time
+	window   time.Duration
+	current  int
+	previous int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: map[string]*memoryCounter{}}
+}
+
+func (s *MemoryStore) Increment(_ context.Context, key string, windowStart time.Time, window time.Duration) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(time.Now())
+
+	c, ok := s.counters[key]
+	switch {
+	case !ok:
+		c = &memoryCounter{start: windowStart, window: window}
+		s.counters[key] = c
+	case c.start.Equal(windowStart):
+	case c.start.Add(window).Equal(windowStart):
+		c.start, c.previous, c.current = windowStart, c.current, 0
+	default:
+		c.start, c.previous, c.current = windowStart, 0, 0
+	}
+	c.window = window
+	c.current++
+
+	return c.current, c.previous, nil
+}
+
+// prune drops counters that can no longer affect a sliding window
+func (s *MemoryStore) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < pruneInterval {
+		return
+	}
+	s.lastPrune = now
+
+	for key, c := range s.counters {
+		if now.After(c.start.Add(2 * c.window)) {
+			delete(s.counters, key)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresStore shares counters between instances through the rate_limit_counters table
+type PostgresStore struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Increment(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int, int, error) {
+	s.prune(ctx, time.Now())
+
+	var counts struct {
+		Current  int
+		Previous int
+	}
+	err := s.db.WithContext(ctx).Raw(`
+		WITH hit AS (
+			INSERT INTO rate_limit_counters (bucket, window_start, count, expires_at)
+			VALUES (?, ?, 1, ?)
+			ON CONFLICT (bucket, window_start) DO UPDATE SET count = rate_limit_counters.count + 1
+			RETURNING count
+		)
+		SELECT (SELECT count FROM hit) AS current,
+			COALESCE((SELECT count FROM rate_limit_counters WHERE bucket = ? AND window_start = ?), 0) AS previous`,
+		key, windowStart, windowStart.Add(2*window), key, windowStart.Add(-window)).
+		Scan(&counts).Error
+
+	return counts.Current, counts.Previous, err
+}
+
+// prune deletes expired counters, at most once per interval per instance
+func (s *PostgresStore) prune(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	due := now.Sub(s.lastPrune) >= pruneInterval
+	if due {
+		s.lastPrune = now
+	}
+	s.mu.Unlock()
+
+	if !due {
+		return
+	}
+	if err := s.db.WithContext(ctx).Exec("DELETE FROM rate_limit_counters WHERE expires_at < ?", now).Error; err != nil {
+		log.Printf("Error pruning rate limit counters: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+// Package ratelimit counts requests in sliding windows so abusive clients can
+// be throttled. Counters live in memory or in Postgres.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps fixed-window counters; the sliding window is estimated from the
+// current and the previous window
+type Store interface {
+	// Increment adds one to the counter of key for the window starting at
+	// windowStart and returns it along with the previous window's count
+	Increment(ctx context.Context, key string, windowStart time.Time, window time.Duration) (current, previous int, err error)
+}
+
+// Result is the outcome of one rate limit check
+type Result struct {
+	Allowed bool
+	// RetryAfter is how long to wait before the next request can succeed
+	RetryAfter time.Duration
+}
+
+// pruneInterval is how often stores drop counters older than two windows
+const pruneInterval = time.Minute
+
+// Allow records a request against key and reports whether it stays within
+// limit requests per sliding window. The previous window counts in proportion
+// to how much of it still overlaps the sliding window.
+func Allow(ctx context.Context, store Store, key string, limit int, window time.Duration, now time.Time) (Result, error) {
+	start := now.Truncate(window)
+	current, previous, err := store.Increment(ctx, key, start, window)
+	if err != nil {
+		return Result{Allowed: true}, err
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(window)
+	if float64(previous)*weight+float64(current) <= float64(limit) {
+		return Result{Allowed: true}, nil
+	}
+
+	return Result{RetryAfter: retryAfter(limit, current, previous, elapsed, window)}, nil
+}
+
+// retryAfter estimates when one more request would fit in the budget
+func retryAfter(limit, current, previous int, elapsed, window time.Duration) time.Duration {
+	wait := window - elapsed
+	if current < limit {
+		// Still room in this window once the previous window decays enough:
+		// previous*(1-(elapsed+t)/window) + current + 1 <= limit
+		wait = time.Duration(float64(window)*(1-float64(limit-current-1)/float64(previous))) - elapsed
+	} else if limit > 0 {
+		// This window is spent; wait until its share of the next one is small enough:
+		// current*(1-x/window) + 1 <= limit
+		wait += time.Duration(float64(window) * (1 - float64(limit-1)/float64(current)))
+	}
+
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait
+}
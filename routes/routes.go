@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"vcm-medical-platform/handlers"
+	"vcm-medical-platform/middleware"
+	"vcm-medical-platform/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Setup mounts the versioned REST API on the application
+func Setup(app *fiber.App) {
+	// Public keys for verifying access tokens
+	app.Get("/.well-known/jwks.json", handlers.JWKS)
+	app.Get("/.well-known/openid-configuration", handlers.OpenIDConfiguration)
+
+	api := app.Group("/api/v1")
+
+	// Public authentication routes
+	auth := api.Group("/auth")
+	auth.Post("/register", middleware.RateLimit("register"), handlers.Register)
+	auth.Post("/login", middleware.RateLimit("login"), handlers.Login)
+	auth.Post("/verify-otp", middleware.RateLimit("verify_otp"), handlers.VerifyOTP)
+	auth.Post("/resend-otp", middleware.RateLimit("resend_otp"), handlers.ResendOTP)
+	auth.Post("/complete-profile", handlers.CompleteProfile)
+	auth.Post("/refresh", handlers.RefreshToken)
+	auth.Post("/password/forgot", middleware.RateLimit("password_forgot"), handlers.ForgotPassword)
+	auth.Post("/password/reset", middleware.RateLimit("password_reset"), handlers.ResetPassword)
+
+	// Passwordless login by emailed link or code
+	auth.Post("/passwordless", middleware.RateLimit("passwordless"), handlers.StartPasswordlessLogin)
+	auth.Post("/passwordless/code", middleware.RateLimit("passwordless_use"), handlers.VerifyPasswordlessCode)
+	auth.Post("/passwordless/link", middleware.RateLimit("passwordless_use"), handlers.RedeemLoginLink)
+
+	// Log in with WeChat
+	auth.Get("/wechat/authorize", handlers.WeChatAuthorize)
+	auth.Post("/wechat/callback", middleware.RateLimit("wechat_callback"), handlers.WeChatCallback)
+	auth.Post("/wechat/register", middleware.RateLimit("wechat_register"), handlers.WeChatRegister)
+	auth.Post("/wechat/link", middleware.AuthMiddleware, middleware.RequireSession, handlers.StartWeChatLink)
+	auth.Delete("/wechat/link", middleware.AuthMiddleware, middleware.RequireSession, handlers.UnlinkWeChat)
+
+	// Second login step for accounts with two-factor authentication
+	auth.Post("/login/2fa", middleware.RateLimit("login_2fa"), handlers.VerifyLoginMFA)
+	auth.Post("/login/2fa/setup", middleware.RateLimit("login_2fa"), handlers.SetupLoginTOTP)
+	auth.Post("/login/2fa/confirm", middleware.RateLimit("login_2fa"), handlers.ConfirmLoginTOTP)
+	auth.Post("/login/2fa/passkey/begin", middleware.RateLimit("login_2fa"), handlers.BeginPasskeyMFA)
+	auth.Post("/login/2fa/passkey/finish", middleware.RateLimit("login_2fa"), handlers.FinishPasskeyMFA)
+
+	// Passkey login, without a password
+	auth.Post("/passkey/begin", middleware.RateLimit("passkey_login"), handlers.BeginPasskeyLogin)
+	auth.Post("/passkey/finish", middleware.RateLimit("passkey_login"), handlers.FinishPasskeyLogin)
+
+	// Protected authentication routes
+	auth.Get("/me", middleware.AllowPendingConsent, middleware.AuthMiddleware, handlers.GetMe)
+	auth.Get("/me/permissions", middleware.AuthMiddleware, handlers.GetMyPermissions)
+	auth.Get("/me/login-history", middleware.AuthMiddleware, middleware.RequireSession, handlers.GetLoginHistory)
+	auth.Post("/logout", middleware.AllowPendingConsent, middleware.AuthMiddleware, middleware.RequireSession, handlers.Logout)
+	auth.Get("/me/notifications", middleware.AuthMiddleware, handlers.GetNotificationSettings)
+	auth.Put("/me/notifications", middleware.AuthMiddleware, middleware.RequireSession, handlers.UpdateNotificationChannel)
+
+	// Consent to terms, privacy policy and optional uses of data. These work
+	// while changed documents are waiting to be accepted.
+	consents := auth.Group("/me/consents", middleware.AllowPendingConsent, middleware.AuthMiddleware, middleware.RequireSession)
+	consents.Get("/", handlers.GetMyConsents)
+	consents.Post("/", handlers.AcceptConsents)
+	consents.Get("/history", handlers.GetConsentHistory)
+	consents.Delete("/:kind", handlers.WithdrawConsent)
+
+	// Personal data export and erasure for the current user, which stay
+	// available to users who decline changed documents
+	auth.Get("/me/data-requests", middleware.AllowPendingConsent, middleware.AuthMiddleware, middleware.RequireSession, handlers.ListDataRequests)
+	auth.Post("/me/data-export", middleware.AllowPendingConsent, middleware.AuthMiddleware, middleware.RequireSession, handlers.RequestDataExport)
+	auth.Get("/me/data-export/:id", middleware.AllowPendingConsent, middleware.AuthMiddleware, middleware.RequireSession, handlers.DownloadDataExport)
+	auth.Post("/me/erasure", middleware.AllowPendingConsent, middleware.AuthMiddleware, middleware.RequireSession, handlers.RequestErasure)
+	auth.Delete("/me/erasure", middleware.AllowPendingConsent, middleware.AuthMiddleware, middleware.RequireSession, handlers.CancelErasure)
+
+	// Changing the email or phone number; the old address can undo a change
+	auth.Post("/me/email", middleware.AuthMiddleware, middleware.RequireSession, handlers.RequestEmailChange)
+	auth.Post("/me/email/confirm", middleware.AuthMiddleware, middleware.RequireSession, handlers.ConfirmEmailChange)
+	auth.Post("/me/phone", middleware.AuthMiddleware, middleware.RequireSession, handlers.RequestPhoneChange)
+	auth.Post("/me/phone/confirm", middleware.AuthMiddleware, middleware.RequireSession, handlers.ConfirmPhoneChange)
+	auth.Post("/contact-change/undo", middleware.RateLimit("contact_undo"), handlers.UndoContactChange)
+
+	// Device sessions of the current user
+	sessions := auth.Group("/sessions", middleware.AuthMiddleware, middleware.RequireSession)
+	sessions.Get("/", handlers.ListSessions)
+	sessions.Delete("/", handlers.RevokeOtherSessions)
+	sessions.Delete("/:id", handlers.RevokeSession)
+
+	// Partner portals the current user has allowed to sign them in
+	connectedApps := auth.Group("/connected-apps", middleware.AuthMiddleware, middleware.RequireSession)
+	connectedApps.Get("/", handlers.ListConnectedApps)
+	connectedApps.Delete("/:clientId", handlers.RevokeConnectedApp)
+
+	// API keys for partner system integrations; a key cannot manage keys
+	apiKeys := auth.Group("/api-keys", middleware.AuthMiddleware, middleware.RequireSession)
+	apiKeys.Get("/", handlers.ListAPIKeys)
+	apiKeys.Post("/", middleware.RequirePermission(models.PermAPIKeysManage), handlers.CreateAPIKey)
+	apiKeys.Delete("/:id", handlers.RevokeAPIKey)
+
+	// Two-factor management
+	mfa := auth.Group("/2fa", middleware.AuthMiddleware, middleware.RequireSession)
+	mfa.Get("/", handlers.GetMFAStatus)
+	mfa.Post("/totp/setup", handlers.SetupTOTP)
+	mfa.Post("/totp/confirm", handlers.ConfirmTOTP)
+	mfa.Delete("/totp", handlers.DisableTOTP)
+	mfa.Post("/recovery-codes", handlers.RegenerateRecoveryCodes)
+	mfa.Get("/passkeys", handlers.ListPasskeys)
+	mfa.Post("/passkeys/begin", handlers.BeginPasskeyRegistration)
+	mfa.Post("/passkeys/finish", handlers.FinishPasskeyRegistration)
+	mfa.Delete("/passkeys/:id", handlers.DeletePasskey)
+
+	// OpenID Connect provider for partner portals. The consent page calls the
+	// authorize endpoints on behalf of the signed-in user.
+	oauth := api.Group("/oauth")
+	oauth.Get("/authorize", middleware.AuthMiddleware, middleware.RequireSession, handlers.GetAuthorizationRequest)
+	oauth.Post("/authorize", middleware.AuthMiddleware, middleware.RequireSession, handlers.Authorize)
+	oauth.Post("/token", handlers.OAuthToken)
+	oauth.Get("/userinfo", handlers.UserInfo)
+	oauth.Post("/userinfo", handlers.UserInfo)
+
+	// Documents in force, for the registration page
+	api.Get("/consents/documents", handlers.ListConsentDocuments)
+
+	// Public location lookups
+	locations := api.Group("/locations")
+	locations.Get("/countries", handlers.GetCountries)
+	locations.Get("/countries/:countryId/states", handlers.GetStates)
+	locations.Get("/countries/:countryId/states/:stateId/cities", handlers.GetCities)
+	locations.Get("/countries/:countryId/states/:stateId/cities/:cityId/districts", handlers.GetDistricts)
+
+	// Administration, gated per route by database-backed permissions
+	admin := api.Group("/admin", middleware.AuthMiddleware)
+	admin.Put("/users/:id/status", middleware.RequirePermission(models.PermUsersStatusManage), handlers.UpdateUserStatus)
+	admin.Get("/users/:id/status-history", middleware.RequirePermission(models.PermUsersRead), handlers.GetUserStatusHistory)
+	admin.Get("/users/:id/notifications", middleware.RequirePermission(models.PermUsersRead), handlers.GetUserNotifications)
+	admin.Get("/users/:id/sessions", middleware.RequirePermission(models.PermUsersRead), handlers.ListUserSessions)
+	admin.Get("/users/:id/login-history", middleware.RequirePermission(models.PermUsersRead), handlers.GetUserLoginHistory)
+	admin.Get("/login-events", middleware.RequirePermission(models.PermUsersRead), handlers.SearchLoginEvents)
+	admin.Delete("/users/:id/sessions", middleware.RequirePermission(models.PermUsersSessionsManage), handlers.RevokeUserSessions)
+	admin.Get("/users/:id/api-keys", middleware.RequirePermission(models.PermUsersRead), handlers.ListUserAPIKeys)
+	admin.Delete("/users/:id/api-keys/:keyId", middleware.RequirePermission(models.PermUsersAPIKeysManage), handlers.RevokeUserAPIKey)
+	admin.Get("/users/:id/data-requests", middleware.RequirePermission(models.PermUsersRead), handlers.ListUserDataRequests)
+	admin.Post("/users/:id/data-export", middleware.RequirePermission(models.PermUsersDataExport), handlers.ExportUserData)
+	admin.Get("/users/:id/data-export/:requestId", middleware.RequirePermission(models.PermUsersDataExport), handlers.DownloadUserDataExport)
+	admin.Post("/users/:id/erasure", middleware.RequirePermission(models.PermUsersDataErase), handlers.EraseUserData)
+	admin.Delete("/users/:id/erasure", middleware.RequirePermission(models.PermUsersDataErase), handlers.CancelUserErasure)
+	admin.Get("/users/:id/consents", middleware.RequirePermission(models.PermUsersRead), handlers.GetUserConsents)
+	admin.Get("/consent-documents", middleware.RequirePermission(models.PermConsentsManage), handlers.ListAllConsentDocuments)
+	admin.Post("/consent-documents", middleware.RequirePermission(models.PermConsentsManage), handlers.CreateConsentDocument)
+
+	invitations := admin.Group("/invitations", middleware.RequirePermission(models.PermInvitationsManage))
+	invitations.Post("/", handlers.CreateInvitation)
+	invitations.Get("/", handlers.ListInvitations)
+	invitations.Post("/:id/resend", handlers.ResendInvitation)
+	invitations.Delete("/:id", handlers.RevokeInvitation)
+
+	rbac := admin.Group("/rbac", middleware.RequirePermission(models.PermRBACManage))
+	rbac.Get("/roles", handlers.ListRoles)
+	rbac.Get("/permissions", handlers.ListPermissions)
+	rbac.Put("/roles/:id/permissions", handlers.SetRolePermissions)
+	rbac.Get("/users/:id/permissions", handlers.GetUserPermissions)
+	rbac.Put("/users/:id/permissions/:code", handlers.SetPermissionOverride)
+	rbac.Delete("/users/:id/permissions/:code", handlers.DeletePermissionOverride)
+
+	oauthClients := admin.Group("/oauth/clients", middleware.RequirePermission(models.PermOAuthClientsManage))
+	oauthClients.Post("/", handlers.CreateOAuthClient)
+	oauthClients.Get("/", handlers.ListOAuthClients)
+	oauthClients.Put("/:clientId", handlers.UpdateOAuthClient)
+	oauthClients.Post("/:clientId/secret", handlers.RotateOAuthClientSecret)
+	oauthClients.Delete("/:clientId", handlers.DeleteOAuthClient)
+
+	// Unknown API routes must not fall through to the SPA
+	api.Use(func(c *fiber.Ctx) error {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Route not found",
+		})
+	})
+}
@@ -1,61 +1,180 @@
 package utils
 
 import (
-	"os"
-	"strconv"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"sync"
 	"time"
+	"vcm-medical-platform/config"
 	"vcm-medical-platform/models"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Email    string `json:"email"`
 	UserType int    `json:"user_type"`
+	// SessionID is the refresh token family this access token belongs to
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(user *models.User) (string, error) {
-	expiresHours := 72 // Default 3 days
-	if hours := os.Getenv("JWT_EXPIRES_HOURS"); hours != "" {
-		if h, err := strconv.Atoi(hours); err == nil {
-			expiresHours = h
+// SigningKey is one Ed25519 key of the ring. It signs new tokens between
+// NotBefore and ExpiresAt and keeps verifying until RetiresAt, so tokens
+// issued just before a rotation stay valid for their whole lifetime.
+type SigningKey struct {
+	ID         string
+	PrivateKey ed25519.PrivateKey
+	NotBefore  time.Time
+	ExpiresAt  time.Time
+	RetiresAt  time.Time
+}
+
+// JSONWebKey is the public half of a signing key in RFC 8037 (OKP) form
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+var ErrNoSigningKey = errors.New("no active JWT signing key")
+
+var (
+	jwtConfig config.JWTConfig
+
+	keyRingMu sync.RWMutex
+	keyRing   []SigningKey
+)
+
+// InitJWT sets the signing configuration used by GenerateToken and ValidateToken
+func InitJWT(cfg config.JWTConfig) {
+	jwtConfig = cfg
+}
+
+// SetSigningKeys replaces the key ring, typically after loading it from the database
+func SetSigningKeys(keys []SigningKey) {
+	ring := append([]SigningKey(nil), keys...)
+	// Newest first, so the latest active key wins during an overlap
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].NotBefore.After(ring[j].NotBefore)
+	})
+
+	keyRingMu.Lock()
+	keyRing = ring
+	keyRingMu.Unlock()
+}
+
+// currentSigningKey returns the newest key allowed to sign at now
+func currentSigningKey(now time.Time) (*SigningKey, error) {
+	keyRingMu.RLock()
+	defer keyRingMu.RUnlock()
+
+	for i := range keyRing {
+		k := keyRing[i]
+		if !now.Before(k.NotBefore) && now.Before(k.ExpiresAt) {
+			return &k, nil
 		}
 	}
+	return nil, ErrNoSigningKey
+}
+
+// verificationKey looks up a non-retired key by kid
+func verificationKey(kid string, now time.Time) (ed25519.PublicKey, bool) {
+	keyRingMu.RLock()
+	defer keyRingMu.RUnlock()
 
-	expirationTime := time.Now().Add(time.Duration(expiresHours) * time.Hour)
-	
+	for _, k := range keyRing {
+		if k.ID == kid && now.Before(k.RetiresAt) {
+			return k.PrivateKey.Public().(ed25519.PublicKey), true
+		}
+	}
+	return nil, false
+}
+
+// JWKS lists the public keys that verifiers should trust, including the next
+// key before it starts signing so caches pick it up ahead of the rotation
+func JWKS() JSONWebKeySet {
+	keyRingMu.RLock()
+	defer keyRingMu.RUnlock()
+
+	now := time.Now()
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, k := range keyRing {
+		if !now.Before(k.RetiresAt) {
+			continue
+		}
+		set.Keys = append(set.Keys, JSONWebKey{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k.PrivateKey.Public().(ed25519.PublicKey)),
+			Kid: k.ID,
+			Use: "sig",
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+		})
+	}
+	return set
+}
+
+// SignJWT signs claims with the current key and names it in the kid header
+func SignJWT(claims jwt.Claims) (string, error) {
+	key, err := currentSigningKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.PrivateKey)
+}
+
+// GenerateToken issues a short-lived access token bound to a refresh token family
+func GenerateToken(user *models.User, sessionID string) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID:   user.CdUser,
-		Email:    user.Email,
-		UserType: user.TyUser,
+		UserID:    user.CdUser,
+		Email:     user.Email,
+		UserType:  user.TyUser,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "vcm-medical-platform",
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(jwtConfig.Expire)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    jwtConfig.Issuer,
+			Audience:  jwt.ClaimStrings{jwtConfig.Audience},
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "default-secret-change-in-production"
-	}
-	
-	return token.SignedString([]byte(secret))
+	return SignJWT(claims)
 }
 
+// ValidateToken verifies the signature with the key named by the kid header
+// and enforces expiry, issuer and audience
 func ValidateToken(tokenString string) (*Claims, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "default-secret-change-in-production"
-	}
-
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := verificationKey(kid, time.Now())
+		if !ok {
+			return nil, jwt.ErrTokenUnverifiable
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(jwtConfig.Issuer),
+		jwt.WithAudience(jwtConfig.Audience),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
@@ -67,3 +186,13 @@ func ValidateToken(tokenString string) (*Claims, error) {
 
 	return nil, jwt.ErrInvalidKey
 }
+
+// AccessTokenTTL reports how long issued access tokens stay valid
+func AccessTokenTTL() time.Duration {
+	return jwtConfig.Expire
+}
+
+// RefreshTokenTTL reports how long a refresh token may be exchanged
+func RefreshTokenTTL() time.Duration {
+	return jwtConfig.RefreshExpire
+}
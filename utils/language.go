@@ -0,0 +1,19 @@
+package utils
+
+import "strings"
+
+// PrimaryLanguage returns the lowercase primary subtag of the first non-empty
+// value, which may be a language tag such as "zh-CN" or a whole
+// Accept-Language header. Weights are ignored; browsers list the preferred
+// language first.
+func PrimaryLanguage(values ...string) string {
+	for _, value := range values {
+		tag := strings.TrimSpace(strings.SplitN(value, ",", 2)[0])
+		tag = strings.TrimSpace(strings.SplitN(tag, ";", 2)[0])
+		tag = strings.SplitN(strings.ReplaceAll(tag, "_", "-"), "-", 2)[0]
+		if tag != "" && tag != "*" {
+			return strings.ToLower(tag)
+		}
+	}
+	return ""
+}
@@ -1,11 +1,21 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"math/big"
 )
 
+var otpKey []byte
+
+// InitOTP sets the key used to hash one-time codes before they are stored
+func InitOTP(secret string) {
+	otpKey = []byte(secret)
+}
+
 func GenerateOTP() string {
 	otp := ""
 	for i := 0; i < 6; i++ {
@@ -14,3 +24,16 @@ func GenerateOTP() string {
 	}
 	return otp
 }
+
+// HashOTP returns a keyed digest of a code, so a database leak does not
+// expose codes that could be brute-forced offline
+func HashOTP(code string) string {
+	mac := hmac.New(sha256.New, otpKey)
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckOTP compares a submitted code with a stored hash in constant time
+func CheckOTP(code, hash string) bool {
+	return hmac.Equal([]byte(HashOTP(code)), []byte(hash))
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+	"vcm-medical-platform/config"
+)
+
+var (
+	passwordPolicy config.PasswordConfig
+	// breachedPasswords holds SHA-1 digests of known leaked passwords
+	breachedPasswords map[[sha1.Size]byte]struct{}
+)
+
+func initPasswordPolicy(cfg config.PasswordConfig) error {
+	passwordPolicy = cfg
+	breachedPasswords = nil
+	if cfg.BreachedListPath == "" {
+		return nil
+	}
+
+	f, err := os.Open(cfg.BreachedListPath)
+	if err != nil {
+		return fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	set := map[[sha1.Size]byte]struct{}{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		raw, err := hex.DecodeString(line)
+		if err != nil || len(raw) != sha1.Size {
+			continue
+		}
+		var digest [sha1.Size]byte
+		copy(digest[:], raw)
+		set[digest] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	breachedPasswords = set
+	log.Printf("🔐 Loaded %d breached password hashes", len(set))
+	return nil
+}
+
+// ValidatePassword checks a new password against the policy; the error
+// message is meant for the user
+func ValidatePassword(password, email string) error {
+	length := utf8.RuneCountInString(password)
+	if length < passwordPolicy.MinLength {
+		return fmt.Errorf("Password must be at least %d characters", passwordPolicy.MinLength)
+	}
+	if passwordPolicy.MaxLength > 0 && length > passwordPolicy.MaxLength {
+		return fmt.Errorf("Password must be at most %d characters", passwordPolicy.MaxLength)
+	}
+
+	lower := strings.ToLower(password)
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, _, _ := strings.Cut(email, "@")
+	// Very short local parts would reject too many unrelated passwords
+	if email != "" && (strings.Contains(lower, email) || (len(local) >= 4 && strings.Contains(lower, local))) {
+		return errors.New("Password must not contain your email address")
+	}
+
+	if breachedPasswords != nil {
+		if _, found := breachedPasswords[sha1.Sum([]byte(password))]; found {
+			return errors.New("This password has appeared in a data breach. Please choose a different one.")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// GenerateOpaqueToken returns a random URL-safe token and the hash to store for it
+func GenerateOpaqueToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex SHA-256 digest used to look up opaque tokens
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyCodeChallenge checks a PKCE code_verifier against its S256 code_challenge
+func VerifyCodeChallenge(verifier, challenge string) bool {
+	// RFC 7636 verifiers are 43 to 128 characters
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// APIKeyPrefix starts every API key so it is recognisable in headers, logs and secret scanners
+const APIKeyPrefix = "vcmk_"
+
+// GenerateAPIKey returns a new API key, the short prefix shown to its owner and the hash to store
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	secret, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	key = APIKeyPrefix + secret
+	return key, key[:len(APIKeyPrefix)+8], HashToken(key), nil
+}
+
+// IsAPIKey reports whether a bearer credential is an API key rather than a JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
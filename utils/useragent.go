@@ -0,0 +1,55 @@
+package utils
+
+import "strings"
+
+// uaPattern maps a User-Agent substring to a readable name. Order matters:
+// many agents also claim to be Chrome, Safari or Mac OS X.
+type uaPattern struct {
+	token string
+	name  string
+}
+
+var uaBrowsers = []uaPattern{
+	{"MicroMessenger", "WeChat"},
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Chrome/", "Chrome"},
+	{"Firefox/", "Firefox"},
+	{"Safari/", "Safari"},
+	{"okhttp", "Android app"},
+	{"CFNetwork", "iOS app"},
+}
+
+var uaPlatforms = []uaPattern{
+	{"Windows", "Windows"},
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"Android", "Android"},
+	{"Mac OS X", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// DescribeUserAgent turns a User-Agent header into a short label such as "Chrome on Windows"
+func DescribeUserAgent(ua string) string {
+	browser := matchUserAgent(ua, uaBrowsers)
+	platform := matchUserAgent(ua, uaPlatforms)
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	return "Unknown device"
+}
+
+func matchUserAgent(ua string, patterns []uaPattern) string {
+	for _, p := range patterns {
+		if strings.Contains(ua, p.token) {
+			return p.name
+		}
+	}
+	return ""
+}
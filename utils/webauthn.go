@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"strconv"
+	"vcm-medical-platform/config"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// ErrWebAuthnDisabled is returned when no relying party ID is configured
+var ErrWebAuthnDisabled = errors.New("passkeys are not configured")
+
+var relyingParty *webauthn.WebAuthn
+
+// InitWebAuthn sets up the relying party for passkey ceremonies. Passkeys
+// stay disabled when the config leaves the relying party ID empty.
+func InitWebAuthn(cfg config.WebAuthnConfig) error {
+	if !cfg.IsConfigured() {
+		return nil
+	}
+
+	rp, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPName,
+		RPOrigins:     cfg.Origins,
+	})
+	if err != nil {
+		return err
+	}
+	relyingParty = rp
+	return nil
+}
+
+// WebAuthn returns the relying party, or ErrWebAuthnDisabled
+func WebAuthn() (*webauthn.WebAuthn, error) {
+	if relyingParty == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+	return relyingParty, nil
+}
+
+// WebAuthnUserHandle is the opaque user handle stored on the user's passkeys.
+// It is keyed so the handle does not expose the account number.
+func WebAuthnUserHandle(userID uint) []byte {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte("webauthn-user:" + strconv.FormatUint(uint64(userID), 10)))
+	return mac.Sum(nil)
+}
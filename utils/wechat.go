@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"vcm-medical-platform/config"
+)
+
+// WeChatProfile is what WeChat tells us about a user after authorization
+type WeChatProfile struct {
+	OpenID    string
+	UnionID   string
+	Nickname  string
+	AvatarURL string
+}
+
+type wechatOAuthToken struct {
+	ErrCode     int    `json:"errcode"`
+	ErrMsg      string `json:"errmsg"`
+	AccessToken string `json:"access_token"`
+	OpenID      string `json:"openid"`
+	UnionID     string `json:"unionid"`
+	Scope       string `json:"scope"`
+}
+
+type wechatUserInfo struct {
+	ErrCode    int    `json:"errcode"`
+	ErrMsg     string `json:"errmsg"`
+	OpenID     string `json:"openid"`
+	UnionID    string `json:"unionid"`
+	Nickname   string `json:"nickname"`
+	HeadImgURL string `json:"headimgurl"`
+}
+
+var (
+	wechatOAuthConfig config.WeChatOAuthConfig
+	wechatHTTPClient  = &http.Client{Timeout: 10 * time.Second}
+)
+
+// InitWeChatOAuth sets the app credentials and endpoints for WeChat login
+func InitWeChatOAuth(cfg config.WeChatOAuthConfig) {
+	wechatOAuthConfig = cfg
+}
+
+// WeChatAuthorizeURL is where the browser goes to approve the login
+func WeChatAuthorizeURL(state string) string {
+	q := url.Values{}
+	q.Set("appid", wechatOAuthConfig.AppID)
+	q.Set("redirect_uri", wechatOAuthConfig.RedirectURL)
+	q.Set("response_type", "code")
+	q.Set("scope", wechatOAuthConfig.Scope)
+	q.Set("state", state)
+	return wechatOAuthConfig.AuthorizeURL + "?" + q.Encode() + "#wechat_redirect"
+}
+
+// ExchangeWeChatCode trades an authorization code for the user's WeChat profile
+func ExchangeWeChatCode(ctx context.Context, code string) (*WeChatProfile, error) {
+	q := url.Values{}
+	q.Set("appid", wechatOAuthConfig.AppID)
+	q.Set("secret", wechatOAuthConfig.AppSecret)
+	q.Set("code", code)
+	q.Set("grant_type", "authorization_code")
+
+	var token wechatOAuthToken
+	if err := wechatGet(ctx, wechatOAuthConfig.TokenURL+"?"+q.Encode(), &token); err != nil {
+		return nil, err
+	}
+	if token.ErrCode != 0 || token.OpenID == "" {
+		return nil, fmt.Errorf("wechat oauth: %d: %s", token.ErrCode, token.ErrMsg)
+	}
+
+	profile := &WeChatProfile{OpenID: token.OpenID, UnionID: token.UnionID}
+
+	// snsapi_base grants no profile access; the openid is all we get
+	if !strings.Contains(token.Scope, "userinfo") && !strings.Contains(token.Scope, "snsapi_login") {
+		return profile, nil
+	}
+
+	q = url.Values{}
+	q.Set("access_token", token.AccessToken)
+	q.Set("openid", token.OpenID)
+
+	var info wechatUserInfo
+	if err := wechatGet(ctx, wechatOAuthConfig.UserInfoURL+"?"+q.Encode(), &info); err != nil {
+		return nil, err
+	}
+	if info.ErrCode != 0 {
+		return nil, fmt.Errorf("wechat userinfo: %d: %s", info.ErrCode, info.ErrMsg)
+	}
+	if info.OpenID != token.OpenID {
+		return nil, fmt.Errorf("wechat userinfo: openid mismatch")
+	}
+
+	if info.UnionID != "" {
+		profile.UnionID = info.UnionID
+	}
+	profile.Nickname = info.Nickname
+	profile.AvatarURL = info.HeadImgURL
+	return profile, nil
+}
+
+func wechatGet(ctx context.Context, endpoint string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := wechatHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("wechat: unreadable response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	return nil
+}
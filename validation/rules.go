@@ -0,0 +1,58 @@
+package validation
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"vcm-medical-platform/models"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// rule is a custom validate tag with the message shown when it fails
+type rule struct {
+	check   validator.Func
+	message string
+}
+
+var rules = map[string]rule{
+	"phone": {
+		check:   isPhone,
+		message: "must be an international phone number starting with + and the country code",
+	},
+	"gender": {
+		check:   isOneOf(models.Genders),
+		message: "must be one of " + strings.Join(models.Genders, ", "),
+	},
+	"marital_status": {
+		check:   isOneOf(models.MaritalStatuses),
+		message: "must be one of " + strings.Join(models.MaritalStatuses, ", "),
+	},
+	"past": {
+		check:   isPast,
+		message: "must be in the past",
+	},
+}
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// phoneSeparators may appear in a phone number as typed, e.g. "+86 138-0000-0000"
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+
+// isPhone accepts an E.164 number, ignoring separators
+func isPhone(fl validator.FieldLevel) bool {
+	return e164Pattern.MatchString(phoneSeparators.Replace(fl.Field().String()))
+}
+
+// isOneOf accepts one of the allowed values, ignoring case
+func isOneOf(allowed []string) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return models.MatchProfileValue(allowed, fl.Field().String()) != ""
+	}
+}
+
+// isPast accepts a time before now
+func isPast(fl validator.FieldLevel) bool {
+	t, ok := fl.Field().Interface().(time.Time)
+	return ok && !t.IsZero() && t.Before(time.Now())
+}
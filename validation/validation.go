@@ -0,0 +1,113 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes one invalid field of a request body
+type FieldError struct {
+	// Field is the JSON path of the field, such as "first_name" or "redirect_uris[0]"
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by the names clients send
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	for tag, rule := range rules {
+		if err := v.RegisterValidation(tag, rule.check); err != nil {
+			panic(err)
+		}
+	}
+	return v
+}
+
+// Struct checks the validate tags of v, a struct or pointer to one. It
+// returns nil when every field is valid.
+func Struct(v interface{}) []FieldError {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		// Only a non-struct argument gets here, which is a programming error
+		panic(err)
+	}
+
+	errs := make([]FieldError, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		field := fieldPath(fe.Namespace())
+		errs = append(errs, FieldError{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Message: field + " " + describe(fe),
+		})
+	}
+	return errs
+}
+
+// fieldPath drops the struct name the namespace starts with
+func fieldPath(namespace string) string {
+	if i := strings.IndexByte(namespace, '.'); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+// describe explains a failed rule in words, completing "<field> ..."
+func describe(fe validator.FieldError) string {
+	if rule, ok := rules[fe.Tag()]; ok {
+		return rule.message
+	}
+
+	unit := ""
+	switch fe.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "len":
+		return fmt.Sprintf("must be exactly %s%s", fe.Param(), unit)
+	case "min", "gte":
+		return fmt.Sprintf("must be at least %s%s", fe.Param(), unit)
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s%s", fe.Param(), unit)
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "unique":
+		return "must not contain duplicates"
+	case "url", "http_url":
+		return "must be a valid URL"
+	default:
+		return "is invalid"
+	}
+}